@@ -0,0 +1,326 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "sync"
+)
+
+// Первичная заливка пустых таблиц standin (--bulk-load=auto). Пока таблица заполняется
+// с нуля, построчное обновление индексов и срабатывание триггеров только замедляют загрузку:
+//   - обычные (неуникальные) индексы, не связанные с ограничениями, удаляются
+//     и после загрузки строятся заново, параллельно (до --workers индексов одновременно
+//     на весь процесс, сколько бы таблиц ни завершалось сразу);
+//   - включённые пользовательские триггеры отключаются (ALTER TABLE ... DISABLE TRIGGER)
+//     и после загрузки включаются в прежнем режиме;
+//   - по окончании выполняется ANALYZE, чтобы планировщик standin сразу видел новые данные.
+// PK, уникальные индексы и ограничения не трогаются: по ним работает upsert и проверяется
+// целостность. Что удалено и отключено, записывается в pgsyncer.bulk_load в той же транзакции,
+// так что после падения процесса следующий проход восстановит индексы и триггеры.
+
+// bulkLoadItem — удалённый индекс или отключённый триггер и команда его восстановления
+type bulkLoadItem struct {
+    kind    string // index | trigger
+    name    string
+    restore string
+}
+
+// bulkLoad — таблица standin, заливаемая в режиме первичной загрузки
+type bulkLoad struct {
+    schema string
+    table  string
+    items  []bulkLoadItem
+}
+
+// ensureBulkLoadState — таблица pgsyncer.bulk_load (схему pgsyncer создаёт ensureSyncState)
+func ensureBulkLoadState(ctx context.Context) error {
+    ddl := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s.bulk_load (
+    schema_name text        NOT NULL,
+    table_name  text        NOT NULL,
+    kind        text        NOT NULL,
+    name        text        NOT NULL,
+    restore_sql text        NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (schema_name, table_name, kind, name)
+)`, stateSchema)
+    if _, err := standinDB.ExecContext(ctx, ddl); err != nil {
+        return fmt.Errorf("создание %s.bulk_load: %v", stateSchema, err)
+    }
+    return nil
+}
+
+// beginBulkLoad — если таблица standin пуста, снимает с неё неуникальные индексы и триггеры.
+// nil — таблица не пуста (или режим выключен), загрузка идёт обычным образом.
+func beginBulkLoad(ctx context.Context, cfg *Config, tableName string) (*bulkLoad, error) {
+    if cfg.BulkLoad != "auto" || inSlice(cfg.ReloadTables, tableName) {
+        return nil, nil
+    }
+    schema, table := cfg.StandinSchema, standinTable(cfg, tableName)
+    rel := fmt.Sprintf(`"%s"."%s"`, schema, table)
+
+    // Секционированный родитель (--partition-mode=parent) и отсутствующая таблица — мимо
+    var empty bool
+    err := standinDB.QueryRowContext(ctx, `
+SELECT c.relkind = 'r'
+FROM pg_class c
+WHERE c.oid = to_regclass($1::text)`, rel).Scan(&empty)
+    if err != nil || !empty {
+        return nil, nil
+    }
+    if err := standinDB.QueryRowContext(ctx, fmt.Sprintf(`SELECT NOT EXISTS (SELECT 1 FROM %s)`, rel)).Scan(&empty); err != nil {
+        return nil, fmt.Errorf("проверка пустой таблицы %s: %v", table, err)
+    }
+    if !empty {
+        return nil, nil
+    }
+
+    bl := &bulkLoad{schema: schema, table: table}
+    // Неуникальные индексы без ограничений, зависимостей и родительского секционированного индекса
+    rows, err := standinDB.QueryContext(ctx, `
+SELECT ic.relname, pg_get_indexdef(i.indexrelid)
+FROM pg_index i
+JOIN pg_class ic ON ic.oid = i.indexrelid
+WHERE i.indrelid = to_regclass($1::text)
+  AND NOT i.indisunique
+  AND NOT i.indisreplident
+  AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = i.indexrelid)
+  AND NOT EXISTS (SELECT 1 FROM pg_inherits inh WHERE inh.inhrelid = i.indexrelid)
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d
+                  WHERE d.refclassid = 'pg_class'::regclass AND d.refobjid = i.indexrelid AND d.deptype = 'n')
+ORDER BY ic.relname`, rel)
+    if err != nil {
+        return nil, err
+    }
+    for rows.Next() {
+        var name, def string
+        if err := rows.Scan(&name, &def); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        // IF NOT EXISTS: восстановление после падения может повториться
+        def = strings.Replace(def, " INDEX ", " INDEX IF NOT EXISTS ", 1)
+        bl.items = append(bl.items, bulkLoadItem{kind: "index", name: name, restore: def})
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    // Включённые пользовательские триггеры и режим, в котором их вернуть
+    rows, err = standinDB.QueryContext(ctx, `
+SELECT t.tgname, t.tgenabled::text
+FROM pg_trigger t
+WHERE t.tgrelid = to_regclass($1::text)
+  AND NOT t.tgisinternal
+  AND t.tgenabled <> 'D'
+ORDER BY t.tgname`, rel)
+    if err != nil {
+        return nil, err
+    }
+    for rows.Next() {
+        var name, mode string
+        if err := rows.Scan(&name, &mode); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        enable := "ENABLE"
+        switch mode {
+        case "A":
+            enable = "ENABLE ALWAYS"
+        case "R":
+            enable = "ENABLE REPLICA"
+        }
+        bl.items = append(bl.items, bulkLoadItem{kind: "trigger", name: name,
+            restore: fmt.Sprintf(`ALTER TABLE %s %s TRIGGER "%s"`, rel, enable, name)})
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    if len(bl.items) == 0 {
+        return bl, nil
+    }
+
+    tx, err := standinDB.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+    save := fmt.Sprintf(`
+INSERT INTO %s.bulk_load (schema_name, table_name, kind, name, restore_sql)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (schema_name, table_name, kind, name) DO UPDATE SET restore_sql = EXCLUDED.restore_sql`, stateSchema)
+    for _, it := range bl.items {
+        if _, err := tx.ExecContext(ctx, save, schema, table, it.kind, it.name, it.restore); err != nil {
+            return nil, fmt.Errorf("запись %s.bulk_load: %v", stateSchema, err)
+        }
+        q := fmt.Sprintf(`DROP INDEX "%s"."%s"`, schema, it.name)
+        if it.kind == "trigger" {
+            q = fmt.Sprintf(`ALTER TABLE %s DISABLE TRIGGER "%s"`, rel, it.name)
+        }
+        if _, err := tx.ExecContext(ctx, q); err != nil {
+            return nil, fmt.Errorf("%s: %v", q, err)
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    logFrom(ctx).Info(T("bulk load: empty standin table, indexes and triggers deferred"),
+        "indexes", bl.count("index"), "triggers", bl.count("trigger"))
+    return bl, nil
+}
+
+// count — сколько объектов вида kind снято с таблицы
+func (bl *bulkLoad) count(kind string) int {
+    n := 0
+    for _, it := range bl.items {
+        if it.kind == kind {
+            n++
+        }
+    }
+    return n
+}
+
+// finish — возвращает триггеры, параллельно строит индексы и выполняет ANALYZE.
+// Вызывается и после ошибки или остановки: таблица не должна остаться без индексов.
+func (bl *bulkLoad) finish(ctx context.Context, workers int) error {
+    if err := restoreBulkLoadItems(ctx, bl.schema, bl.table, bl.items, workers); err != nil {
+        return err
+    }
+    if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`ANALYZE "%s"."%s"`, bl.schema, bl.table)); err != nil {
+        return fmt.Errorf("ANALYZE %s: %v", bl.table, err)
+    }
+    logFrom(ctx).Info(T("bulk load: indexes rebuilt, table analyzed"), "indexes", bl.count("index"))
+    return nil
+}
+
+// indexBuilds — общий на процесс предел одновременных CREATE INDEX в standin.
+// finish вызывают воркеры таблиц, каждый со своими потоками: без общего предела
+// одновременно строилось бы до workers² индексов, каждый со своим maintenance_work_mem.
+var indexBuilds struct {
+    once  sync.Once
+    slots chan struct{}
+}
+
+// acquireIndexBuild — занимает место для построения индекса; возвращает его освобождение
+func acquireIndexBuild(workers int) func() {
+    indexBuilds.once.Do(func() {
+        indexBuilds.slots = make(chan struct{}, workers)
+    })
+    indexBuilds.slots <- struct{}{}
+    return func() { <-indexBuilds.slots }
+}
+
+// restoreBulkLoadItems — выполняет команды восстановления: сначала триггеры, затем индексы
+// в workers потоков (в пределах общего на процесс числа, см. indexBuilds).
+// Восстановленное удаляется из pgsyncer.bulk_load.
+func restoreBulkLoadItems(ctx context.Context, schema, table string, items []bulkLoadItem, workers int) error {
+    done := fmt.Sprintf(`DELETE FROM %s.bulk_load WHERE schema_name = $1 AND table_name = $2 AND kind = $3 AND name = $4`, stateSchema)
+    restore := func(it bulkLoadItem) error {
+        if _, err := standinDB.ExecContext(ctx, it.restore); err != nil {
+            return fmt.Errorf("%s: %v", it.restore, err)
+        }
+        _, err := standinDB.ExecContext(ctx, done, schema, table, it.kind, it.name)
+        return err
+    }
+
+    var indexes []bulkLoadItem
+    for _, it := range items {
+        if it.kind != "trigger" {
+            indexes = append(indexes, it)
+            continue
+        }
+        if err := restore(it); err != nil {
+            return err
+        }
+    }
+
+    if workers < 1 {
+        workers = 1
+    }
+    itemCh := make(chan bulkLoadItem, len(indexes))
+    for _, it := range indexes {
+        itemCh <- it
+    }
+    close(itemCh)
+
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    var firstErr error
+    for i := 0; i < workers && i < len(indexes); i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for it := range itemCh {
+                release := acquireIndexBuild(workers)
+                err := restore(it)
+                release()
+                if err != nil {
+                    mu.Lock()
+                    if firstErr == nil {
+                        firstErr = err
+                    }
+                    mu.Unlock()
+                }
+            }
+        }()
+    }
+    wg.Wait()
+    return firstErr
+}
+
+// restoreBulkLoads — восстанавливает индексы и триггеры, оставшиеся снятыми после
+// прерванной первичной заливки (процесс упал, не дойдя до finish)
+func restoreBulkLoads(ctx context.Context, cfg *Config) error {
+    q := fmt.Sprintf(`
+SELECT table_name, kind, name, restore_sql
+FROM %s.bulk_load
+WHERE schema_name = $1
+ORDER BY table_name, kind DESC, name`, stateSchema)
+    rows, err := standinDB.QueryContext(ctx, q, cfg.StandinSchema)
+    if err != nil {
+        return fmt.Errorf("чтение %s.bulk_load: %v", stateSchema, err)
+    }
+    pending := make(map[string][]bulkLoadItem)
+    var tables []string
+    for rows.Next() {
+        var table string
+        var it bulkLoadItem
+        if err := rows.Scan(&table, &it.kind, &it.name, &it.restore); err != nil {
+            rows.Close()
+            return err
+        }
+        if pending[table] == nil {
+            tables = append(tables, table)
+        }
+        pending[table] = append(pending[table], it)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    for _, table := range tables {
+        var exists bool
+        rel := fmt.Sprintf(`"%s"."%s"`, cfg.StandinSchema, table)
+        if err := standinDB.QueryRowContext(ctx, `SELECT to_regclass($1::text) IS NOT NULL`, rel).Scan(&exists); err != nil {
+            return err
+        }
+        if !exists {
+            // Таблицу с тех пор удалили — восстанавливать не на чем
+            q := fmt.Sprintf(`DELETE FROM %s.bulk_load WHERE schema_name = $1 AND table_name = $2`, stateSchema)
+            if _, err := standinDB.ExecContext(ctx, q, cfg.StandinSchema, table); err != nil {
+                return err
+            }
+            continue
+        }
+        logFrom(ctx).Warn(T("restoring indexes and triggers left by interrupted bulk load"), "table", table, "objects", len(pending[table]))
+        if err := restoreBulkLoadItems(ctx, cfg.StandinSchema, table, pending[table], cfg.Workers); err != nil {
+            // Запись остаётся в bulk_load: следующий проход попробует снова
+            logFrom(ctx).Warn(T("bulk load restore failed"), "table", table, "error", err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+)
+
+// Объекты trigger-захвата живут в основной БД в отдельной схеме pgsyncer,
+// чтобы pg_dump --schema=<cfg.Schema> не переносил их в резервную БД.
+const (
+    captureSchema          = "pgsyncer"
+    captureTriggerName     = "pgsyncer_capture"
+    captureTruncateTrigger = "pgsyncer_capture_truncate"
+)
+
+// installCapture — создаёт в mainDB таблицу pgsyncer.changelog, аудит-функции
+// и вешает триггеры на выбранные таблицы (cfg.CaptureTables или все таблицы схемы).
+func installCapture(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "capture", "schema", cfg.Schema)
+
+    tables := cfg.CaptureTables
+    if len(tables) == 0 {
+        var err error
+        tables, err = listTables(ctx, mainDB, cfg.Schema)
+        if err != nil {
+            return fmt.Errorf("listTables(mainDB): %v", err)
+        }
+    }
+    // Триггеры ставятся на конечные секции: строки синхронизируются по ним (--partition-mode=leaf)
+    parts, err := loadPartitions(ctx, mainDB, cfg.Schema)
+    if err != nil {
+        return fmt.Errorf("loadPartitions(mainDB): %v", err)
+    }
+    tables = parts.selectLevel(tables, "leaf")
+
+    tx, err := mainDB.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("BeginTx mainDB: %v", err)
+    }
+    defer tx.Rollback()
+
+    // 1) Служебная схема, журнал изменений и реестр подключённых таблиц
+    ddl := fmt.Sprintf(`
+CREATE SCHEMA IF NOT EXISTS %[1]s;
+
+CREATE TABLE IF NOT EXISTS %[1]s.changelog (
+    id          bigserial PRIMARY KEY,
+    txid        bigint      NOT NULL DEFAULT txid_current(),
+    schema_name text        NOT NULL,
+    table_name  text        NOT NULL,
+    op          char(1)     NOT NULL,
+    pk          jsonb       NOT NULL,
+    changed_at  timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS changelog_table_idx ON %[1]s.changelog (schema_name, table_name, txid, id);
+
+CREATE TABLE IF NOT EXISTS %[1]s.capture_tables (
+    schema_name  text        NOT NULL,
+    table_name   text        NOT NULL,
+    initialized  boolean     NOT NULL DEFAULT false,
+    installed_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (schema_name, table_name)
+);
+`, captureSchema)
+    if _, err := tx.ExecContext(ctx, ddl); err != nil {
+        return fmt.Errorf("создание pgsyncer.changelog: %v", err)
+    }
+
+    // 2) Аудит-функция: пишет в журнал ключ изменённой строки (столбцы PK приходят в TG_ARGV).
+    // UPDATE, меняющий PK, записывается как удаление старого ключа + изменение нового.
+    rowFunc := fmt.Sprintf(`
+CREATE OR REPLACE FUNCTION %[1]s.capture_change() RETURNS trigger
+LANGUAGE plpgsql AS $fn$
+DECLARE
+    rec     jsonb;
+    old_key jsonb := '{}';
+    new_key jsonb := '{}';
+    i       int;
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        rec := to_jsonb(OLD);
+        FOR i IN 0..TG_NARGS-1 LOOP
+            old_key := old_key || jsonb_build_object(TG_ARGV[i], rec -> TG_ARGV[i]);
+        END LOOP;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        rec := to_jsonb(NEW);
+        FOR i IN 0..TG_NARGS-1 LOOP
+            new_key := new_key || jsonb_build_object(TG_ARGV[i], rec -> TG_ARGV[i]);
+        END LOOP;
+    END IF;
+
+    IF TG_OP = 'DELETE' OR (TG_OP = 'UPDATE' AND old_key <> new_key) THEN
+        INSERT INTO %[1]s.changelog (schema_name, table_name, op, pk)
+        VALUES (TG_TABLE_SCHEMA, TG_TABLE_NAME, 'D', old_key);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO %[1]s.changelog (schema_name, table_name, op, pk)
+        VALUES (TG_TABLE_SCHEMA, TG_TABLE_NAME, left(TG_OP, 1), new_key);
+    END IF;
+    RETURN NULL;
+END
+$fn$;
+`, captureSchema)
+    if _, err := tx.ExecContext(ctx, rowFunc); err != nil {
+        return fmt.Errorf("создание %s.capture_change(): %v", captureSchema, err)
+    }
+
+    // TRUNCATE нельзя выразить набором ключей — сбрасываем флаг initialized,
+    // и на следующем запуске таблица пройдёт полную синхронизацию.
+    truncFunc := fmt.Sprintf(`
+CREATE OR REPLACE FUNCTION %[1]s.capture_truncate() RETURNS trigger
+LANGUAGE plpgsql AS $fn$
+BEGIN
+    DELETE FROM %[1]s.changelog
+    WHERE schema_name = TG_TABLE_SCHEMA AND table_name = TG_TABLE_NAME;
+    UPDATE %[1]s.capture_tables SET initialized = false
+    WHERE schema_name = TG_TABLE_SCHEMA AND table_name = TG_TABLE_NAME;
+    RETURN NULL;
+END
+$fn$;
+`, captureSchema)
+    if _, err := tx.ExecContext(ctx, truncFunc); err != nil {
+        return fmt.Errorf("создание %s.capture_truncate(): %v", captureSchema, err)
+    }
+
+    // 3) Триггеры на таблицы
+    installed := 0
+    for _, t := range tables {
+        pkCols, _ := detectPK(withLogAttrs(ctx, "table", t), tx, cfg.Schema, t)
+        if len(pkCols) == 0 {
+            logFrom(ctx).Warn(T("table has no PK, trigger capture impossible, skipping"), "table", t)
+            continue
+        }
+
+        args := make([]string, len(pkCols))
+        for i, c := range pkCols {
+            args[i] = "'" + strings.ReplaceAll(c, "'", "''") + "'"
+        }
+
+        stmts := []string{
+            fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON "%s"."%s"`, captureTriggerName, cfg.Schema, t),
+            fmt.Sprintf(`CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON "%s"."%s" FOR EACH ROW EXECUTE PROCEDURE %s.capture_change(%s)`,
+                captureTriggerName, cfg.Schema, t, captureSchema, strings.Join(args, ", ")),
+            fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON "%s"."%s"`, captureTruncateTrigger, cfg.Schema, t),
+            fmt.Sprintf(`CREATE TRIGGER %s AFTER TRUNCATE ON "%s"."%s" FOR EACH STATEMENT EXECUTE PROCEDURE %s.capture_truncate()`,
+                captureTruncateTrigger, cfg.Schema, t, captureSchema),
+        }
+        for _, stmt := range stmts {
+            if _, err := tx.ExecContext(ctx, stmt); err != nil {
+                return fmt.Errorf("триггер на %s: %v", t, err)
+            }
+        }
+
+        regSQL := fmt.Sprintf(`
+INSERT INTO %s.capture_tables (schema_name, table_name)
+VALUES ($1, $2)
+ON CONFLICT (schema_name, table_name) DO NOTHING`, captureSchema)
+        if _, err := tx.ExecContext(ctx, regSQL, cfg.Schema, t); err != nil {
+            return fmt.Errorf("регистрация %s в capture_tables: %v", t, err)
+        }
+        installed++
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("Commit installCapture: %v", err)
+    }
+    logFrom(ctx).Info(T("trigger capture installed"), "tables", installed)
+    return nil
+}
+
+// uninstallCapture — снимает триггеры со всех таблиц и удаляет объекты pgsyncer из mainDB.
+func uninstallCapture(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "capture")
+
+    tx, err := mainDB.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("BeginTx mainDB: %v", err)
+    }
+    defer tx.Rollback()
+
+    // Ищем триггеры по pg_trigger, а не по реестру — он мог разойтись с реальностью
+    q := `
+SELECT DISTINCT n.nspname, c.relname
+FROM pg_trigger tg
+JOIN pg_class c ON c.oid = tg.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE tg.tgname IN ($1, $2);
+`
+    rows, err := tx.QueryContext(ctx, q, captureTriggerName, captureTruncateTrigger)
+    if err != nil {
+        return fmt.Errorf("поиск триггеров pgsyncer: %v", err)
+    }
+    type relName struct{ schema, table string }
+    var rels []relName
+    for rows.Next() {
+        var r relName
+        if err := rows.Scan(&r.schema, &r.table); err != nil {
+            rows.Close()
+            return err
+        }
+        rels = append(rels, r)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    for _, r := range rels {
+        for _, trg := range []string{captureTriggerName, captureTruncateTrigger} {
+            stmt := fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON "%s"."%s"`, trg, r.schema, r.table)
+            if _, err := tx.ExecContext(ctx, stmt); err != nil {
+                return fmt.Errorf("DROP TRIGGER на %s.%s: %v", r.schema, r.table, err)
+            }
+        }
+        logFrom(ctx).Info(T("capture triggers removed"), "schema", r.schema, "table", r.table)
+    }
+
+    cleanup := fmt.Sprintf(`
+DROP FUNCTION IF EXISTS %[1]s.capture_change();
+DROP FUNCTION IF EXISTS %[1]s.capture_truncate();
+DROP TABLE IF EXISTS %[1]s.changelog;
+DROP TABLE IF EXISTS %[1]s.capture_tables;
+`, captureSchema)
+    if _, err := tx.ExecContext(ctx, cleanup); err != nil {
+        return fmt.Errorf("удаление объектов %s: %v", captureSchema, err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("Commit uninstallCapture: %v", err)
+    }
+
+    // Схему удаляем без CASCADE: если в ней лежит что-то чужое — оставляем
+    if _, err := mainDB.ExecContext(ctx, fmt.Sprintf(`DROP SCHEMA IF EXISTS %s`, captureSchema)); err != nil {
+        logFrom(ctx).Warn(T("schema not dropped (not empty?)"), "schema", captureSchema, "error", err)
+    }
+
+    logFrom(ctx).Info(T("trigger capture uninstalled"), "tables", len(rels))
+    return nil
+}
+
+// captureState — проверяет, подключена ли таблица к trigger-захвату и прошла ли она
+// начальную полную синхронизацию.
+func captureState(ctx context.Context, mainTx *sql.Tx, schema, table string) (registered, initialized bool, err error) {
+    q := fmt.Sprintf(`SELECT initialized FROM %s.capture_tables WHERE schema_name = $1 AND table_name = $2`, captureSchema)
+    err = mainTx.QueryRowContext(snapshotContext(ctx), q, schema, table).Scan(&initialized)
+    if err == sql.ErrNoRows {
+        return false, false, nil
+    }
+    if err != nil {
+        return false, false, err
+    }
+    return true, initialized, nil
+}
+
+// markCaptureInitialized — после полной синхронизации помечает таблицу как инициализированную
+// и очищает журнал: все изменения, видимые в снимке mainTx, уже перенесены.
+func markCaptureInitialized(ctx context.Context, mainTx *sql.Tx, schema, table string) error {
+    q := fmt.Sprintf(`UPDATE %s.capture_tables SET initialized = true WHERE schema_name = $1 AND table_name = $2`, captureSchema)
+    if _, err := mainTx.ExecContext(snapshotContext(ctx), q, schema, table); err != nil {
+        return err
+    }
+    q = fmt.Sprintf(`DELETE FROM %s.changelog WHERE schema_name = $1 AND table_name = $2`, captureSchema)
+    _, err := mainTx.ExecContext(snapshotContext(ctx), q, schema, table)
+    return err
+}
+
+// syncTableByChangelog — вычитывает журнал изменений таблицы и переносит в standinDB
+// ровно изменённые ключи. Ключи обрабатываются в порядке фиксации (txid), а итоговое
+// состояние каждой строки берётся из снимка mainTx: строка есть — upsert, нет — delete.
+// Обработанные записи журнала удаляются в mainTx и исчезают только вместе с его COMMIT.
+func syncTableByChangelog(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string, pkCols []string) error {
+    schema := cfg.Schema
+
+    columns, types, err := syncColumns(ctx, cfg, mainTx, tableName)
+    if err != nil {
+        return fmt.Errorf("[syncTableByChangelog] syncColumns(%s): %v", tableName, err)
+    }
+
+    tr, err := transformFor(cfg, tableName, columns, types, pkCols)
+    if err != nil {
+        return err
+    }
+
+    joinConds := make([]string, len(pkCols))
+    for i, c := range pkCols {
+        joinConds[i] = fmt.Sprintf(`t."%[1]s" = (jsonb_populate_record(NULL::"%[2]s"."%[3]s", c.pk))."%[1]s"`, c, schema, tableName)
+    }
+
+    q := fmt.Sprintf(`
+SELECT c.pk::text, t."%[1]s" IS NULL, COALESCE(%[7]s, false), %[2]s
+FROM (
+    SELECT pk, max(txid) AS last_txid, max(id) AS last_id
+    FROM %[3]s.changelog
+    WHERE schema_name = $1 AND table_name = $2
+    GROUP BY pk
+) c
+LEFT JOIN "%[4]s"."%[5]s" t ON %[6]s
+ORDER BY c.last_txid, c.last_id`,
+        pkCols[0], selectColumns("t", columns, castsFor(cfg, tableName)), captureSchema, schema, tableName, strings.Join(joinConds, " AND "),
+        filterCondAlias(cfg, tableName, "t"))
+
+    release, err := throttleFrom(ctx).acquire(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+    rows, err := mainTx.QueryContext(snapshotContext(ctx), q, schema, tableName)
+    if err != nil {
+        return fmt.Errorf("[syncTableByChangelog] чтение журнала %s: %v", tableName, err)
+    }
+    defer rows.Close()
+
+    batchSize := upsertBatchSize(len(columns), cfg.ChunkSize)
+    var upserts [][]interface{}
+    var deleteKeys []string
+    var batchBytes int64
+    totalUpserts, totalDeletes := 0, 0
+
+    flush := func() error {
+        if len(upserts) == 0 && len(deleteKeys) == 0 {
+            return nil
+        }
+        if err := applyKeyedBatch(ctx, cfg.StandinSchema, standinTable(cfg, tableName), columns, pkCols, upserts, deleteKeys); err != nil {
+            return err
+        }
+        totalUpserts += len(upserts)
+        totalDeletes += len(deleteKeys)
+        metricRowsUpdated.Add(tableName, float64(len(upserts)))
+        metricRowsDeleted.Add(tableName, float64(len(deleteKeys)))
+        metricChunks.Add(tableName, 1)
+        upserts, deleteKeys = nil, nil
+        return nil
+    }
+
+    for rows.Next() {
+        var key string
+        var gone, inFilter bool
+        vals := make([]interface{}, len(columns))
+        ptrs := make([]interface{}, len(columns)+3)
+        ptrs[0], ptrs[1], ptrs[2] = &key, &gone, &inFilter
+        for i := range vals {
+            ptrs[i+3] = &vals[i]
+        }
+        if err := rows.Scan(ptrs...); err != nil {
+            return fmt.Errorf("[syncTableByChangelog] Scan: %v", err)
+        }
+
+        switch {
+        case gone:
+            deleteKeys = append(deleteKeys, key)
+        case !inFilter:
+            // Строка вышла из --filter: это не удаление, в standin она остаётся
+            // (с --filter-purge её уберёт purgeOutsideFilter)
+            continue
+        default:
+            tr.apply(vals)
+            upserts = append(upserts, vals)
+            batchBytes += rowBytes(vals)
+        }
+        if n := len(upserts) + len(deleteKeys); n >= batchSize {
+            if err := flush(); err != nil {
+                return fmt.Errorf("[syncTableByChangelog] %s: %v", tableName, err)
+            }
+            if err := checkStop(ctx); err != nil {
+                return err
+            }
+            if err := throttleFrom(ctx).consume(ctx, n, batchBytes); err != nil {
+                return err
+            }
+            batchBytes = 0
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return err
+    }
+    if err := flush(); err != nil {
+        return fmt.Errorf("[syncTableByChangelog] %s: %v", tableName, err)
+    }
+
+    // Журнал чистим в том же снимке: записи, зафиксированные после его начала, не видны
+    // DELETE и останутся до следующего запуска.
+    delQ := fmt.Sprintf(`DELETE FROM %s.changelog WHERE schema_name = $1 AND table_name = $2`, captureSchema)
+    if _, err := mainTx.ExecContext(snapshotContext(ctx), delQ, schema, tableName); err != nil {
+        return fmt.Errorf("[syncTableByChangelog] очистка журнала %s: %v", tableName, err)
+    }
+
+    logFrom(ctx).Info(T("changelog applied"), "upserted", totalUpserts, "deleted", totalDeletes)
+    return nil
+}
+
+// stripCaptureTriggers — убирает из DDL pg_dump триггеры trigger-захвата:
+// в резервной БД нет функций pgsyncer, да и захватывать там нечего.
+func stripCaptureTriggers(ddl string) string {
+    lines := strings.Split(ddl, "\n")
+    out := lines[:0]
+    for _, line := range lines {
+        if strings.HasPrefix(line, "CREATE TRIGGER "+captureTriggerName) {
+            continue
+        }
+        out = append(out, line)
+    }
+    return strings.Join(out, "\n")
+}
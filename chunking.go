@@ -0,0 +1,136 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Адаптивный размер чанка. --chunk-size задаёт только начальную ширину диапазона PK:
+// после каждого чанка она пересчитывается так, чтобы следующий чанк занял около
+// --chunk-target-duration и (если задан) прочитал из main около --chunk-target-bytes.
+// Для узких таблиц чанки растут, для таблиц с широкими jsonb-строками — уменьшаются,
+// на разреженных диапазонах PK — растут до --chunk-max. За один шаг размер меняется
+// не больше чем вдвое (в меньшую сторону — вчетверо), чтобы один медленный чанк
+// (блокировка, контрольная точка) не обрушил размер. Без обеих целей размер постоянный.
+
+const (
+    chunkMaxGrow   = 2.0
+    chunkMaxShrink = 0.25
+)
+
+// chunkSizer — ширина диапазона PK следующего чанка одной таблицы
+type chunkSizer struct {
+    size        int64
+    min, max    int64
+    targetDur   time.Duration
+    targetBytes int64
+}
+
+// newChunkSizer — начальный размер --chunk-size; границы --chunk-min/--chunk-max действуют при подстройке
+func newChunkSizer(cfg *Config) *chunkSizer {
+    s := &chunkSizer{
+        size:        int64(cfg.ChunkSize),
+        min:         int64(cfg.ChunkMin),
+        max:         int64(cfg.ChunkMax),
+        targetDur:   cfg.ChunkTargetDuration,
+        targetBytes: cfg.ChunkTargetBytes,
+    }
+    if s.size < 1 {
+        s.size = 1
+    }
+    return s
+}
+
+// adaptive — задана ли хотя бы одна цель
+func (s *chunkSizer) adaptive() bool {
+    return s.targetDur > 0 || s.targetBytes > 0
+}
+
+// clamp — размер в границах --chunk-min/--chunk-max (0 — граница не задана)
+func (s *chunkSizer) clamp(n int64) int64 {
+    if s.min > 0 && n < s.min {
+        n = s.min
+    }
+    if s.max > 0 && n > s.max {
+        n = s.max
+    }
+    return n
+}
+
+// observe — учитывает длительность и объём прочитанного чанка и пересчитывает размер.
+// Возвращает true, если размер изменился.
+func (s *chunkSizer) observe(d time.Duration, bytes int64) bool {
+    if !s.adaptive() {
+        return false
+    }
+    factor := chunkMaxGrow
+    if s.targetDur > 0 && d > 0 {
+        factor = min(factor, float64(s.targetDur)/float64(d))
+    }
+    if s.targetBytes > 0 && bytes > 0 {
+        factor = min(factor, float64(s.targetBytes)/float64(bytes))
+    }
+    factor = max(factor, chunkMaxShrink)
+
+    next := s.clamp(int64(float64(s.size) * factor))
+    if next < 1 {
+        next = 1
+    }
+    if next == s.size {
+        return false
+    }
+    s.size = next
+    return true
+}
+
+// rowBytes — примерный объём строки: длина текстовых и бинарных значений, 8 байт на остальные
+func rowBytes(vals []interface{}) int64 {
+    var n int64
+    for _, v := range vals {
+        switch x := v.(type) {
+        case nil:
+        case string:
+            n += int64(len(x))
+        case []byte:
+            n += int64(len(x))
+        default:
+            n += 8
+        }
+    }
+    return n
+}
+
+// parseByteSize — "64MB", "512kB", "1GB" или число байт
+func parseByteSize(s string) (int64, error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return 0, nil
+    }
+    units := []struct {
+        suffix string
+        mult   int64
+    }{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+    upper := strings.ToUpper(s)
+    mult := int64(1)
+    for _, u := range units {
+        if strings.HasSuffix(upper, u.suffix) {
+            mult = u.mult
+            upper = strings.TrimSpace(strings.TrimSuffix(upper, u.suffix))
+            break
+        }
+    }
+    n, err := strconv.ParseInt(upper, 10, 64)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("неверный размер %q (ожидается число байт или 512kB, 64MB, 1GB)", s)
+    }
+    return n * mult, nil
+}
+
+// logChunkSize — пишет в лог и метрику новый размер чанка таблицы
+func logChunkSize(ctx context.Context, tableName string, s *chunkSizer, d time.Duration, bytes int64) {
+    metricChunkSize.Set(tableName, float64(s.size))
+    logFrom(ctx).Debug(T("chunk size adjusted"), "chunk_size", s.size, "last_duration", d.Round(time.Millisecond), "last_bytes", bytes)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+    "sync"
+)
+
+// Сверка столбцов main и standin. Синхронизируются только столбцы, которые есть
+// с обеих сторон (в порядке main); расхождения пишутся в лог, а --column-mismatch
+// решает, что делать со столбцами main, которых нет в standin:
+//   ignore — пропустить (по умолчанию);
+//   add    — добавить в standin (ALTER TABLE ... ADD COLUMN с типом main, без ограничений);
+//   fail   — остановить синхронизацию таблицы.
+// Если типы столбца различаются, --column-cast "таблица.столбец=тип" приводит значение
+// к указанному типу при чтении обеих сторон, так что сравниваются одинаковые представления.
+// Не синхронизируются и не участвуют в сравнении:
+//   - вычисляемые столбцы standin (GENERATED ALWAYS AS ... STORED) — их нельзя записать;
+//   - столбцы из --exclude-columns "таблица.столбец".
+// В столбцы GENERATED ALWAYS AS IDENTITY значения main пишутся с OVERRIDING SYSTEM VALUE.
+
+// columnCasts — приведения --column-cast: таблица ("*" — любая) -> столбец -> тип
+type columnCasts map[string]map[string]string
+
+// parseColumnCasts — разбирает значения --column-cast в map[таблица]map[столбец]тип
+func parseColumnCasts(specs []string) (columnCasts, error) {
+    out := make(columnCasts)
+    for _, spec := range specs {
+        eq := strings.Index(spec, "=")
+        dot := strings.Index(spec, ".")
+        if eq < 0 || dot <= 0 || dot > eq || strings.TrimSpace(spec[eq+1:]) == "" {
+            return nil, fmt.Errorf("неверное приведение %q (ожидается таблица.столбец=тип)", spec)
+        }
+        table, column := strings.TrimSpace(spec[:dot]), strings.TrimSpace(spec[dot+1:eq])
+        if out[table] == nil {
+            out[table] = make(map[string]string)
+        }
+        if _, dup := out[table][column]; dup {
+            return nil, fmt.Errorf("для %s.%s приведение задано дважды", table, column)
+        }
+        out[table][column] = strings.TrimSpace(spec[eq+1:])
+    }
+    return out, nil
+}
+
+// castsFor — приведения столбцов таблицы; правило таблицы важнее правила "*"
+func castsFor(cfg *Config, tableName string) map[string]string {
+    if len(cfg.ColumnCasts) == 0 {
+        return nil
+    }
+    out := make(map[string]string)
+    for c, t := range cfg.ColumnCasts["*"] {
+        out[c] = t
+    }
+    for c, t := range cfg.ColumnCasts[tableName] {
+        out[c] = t
+    }
+    return out
+}
+
+// selectColumns — список SELECT для столбцов: "col" или "col"::тип AS "col"; alias — префикс таблицы
+func selectColumns(alias string, cols []string, casts map[string]string) string {
+    prefix := ""
+    if alias != "" {
+        prefix = alias + "."
+    }
+    out := make([]string, len(cols))
+    for i, c := range cols {
+        out[i] = fmt.Sprintf(`%s"%s"`, prefix, c)
+        if t, ok := casts[c]; ok {
+            out[i] = fmt.Sprintf(`%s"%s"::%s AS "%s"`, prefix, c, t, c)
+        }
+    }
+    return strings.Join(out, ",")
+}
+
+// columnExclusions — исключённые столбцы --exclude-columns: таблица ("*" — любая) -> столбцы
+type columnExclusions map[string][]string
+
+// parseColumnExclusions — разбирает значения --exclude-columns "таблица.столбец[,таблица.столбец]"
+func parseColumnExclusions(specs []string) (columnExclusions, error) {
+    out := make(columnExclusions)
+    for _, spec := range specs {
+        for _, item := range splitList(spec) {
+            dot := strings.Index(item, ".")
+            if dot <= 0 || dot == len(item)-1 {
+                return nil, fmt.Errorf("неверное исключение столбца %q (ожидается таблица.столбец)", item)
+            }
+            table, column := item[:dot], item[dot+1:]
+            if !inSlice(out[table], column) {
+                out[table] = append(out[table], column)
+            }
+        }
+    }
+    return out, nil
+}
+
+// isExcluded — столбец исключён для таблицы (правилом таблицы или "*")
+func isExcluded(cfg *Config, tableName, column string) bool {
+    return inSlice(cfg.ExcludeColumns[tableName], column) || inSlice(cfg.ExcludeColumns["*"], column)
+}
+
+// identityColumns — столбцы GENERATED ALWAYS AS IDENTITY таблиц standin ("схема"."таблица" -> столбцы).
+// Заполняется syncColumns перед синхронизацией таблицы, читается при построении INSERT.
+var identityColumns = struct {
+    sync.Mutex
+    m map[string][]string
+}{m: make(map[string][]string)}
+
+// alwaysIdentity — столбцы GENERATED ALWAYS AS IDENTITY таблицы standin
+func alwaysIdentity(schema, table string) []string {
+    identityColumns.Lock()
+    defer identityColumns.Unlock()
+    return identityColumns.m[fmt.Sprintf(`"%s"."%s"`, schema, table)]
+}
+
+// overridingClause — OVERRIDING SYSTEM VALUE, если среди columns есть столбцы GENERATED ALWAYS AS IDENTITY:
+// иначе PostgreSQL отказывается вставлять в них значения main
+func overridingClause(schema, table string, columns []string) string {
+    for _, c := range alwaysIdentity(schema, table) {
+        if inSlice(columns, c) {
+            return "OVERRIDING SYSTEM VALUE"
+        }
+    }
+    return ""
+}
+
+// columnInfo — столбец таблицы, его тип (format_type) и признаки pg_attribute
+type columnInfo struct {
+    name      string
+    typ       string
+    generated bool   // attgenerated = 's': GENERATED ALWAYS AS (...) STORED
+    identity  string // attidentity: "" — нет, "a" — ALWAYS, "d" — BY DEFAULT
+}
+
+// listColumns — столбцы таблицы в порядке attnum; пусто, если таблицы нет
+func listColumns(ctx context.Context, db interface {
+    QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}, schema, table string) ([]columnInfo, error) {
+    q := `
+SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attgenerated <> '', a.attidentity::text
+FROM pg_attribute a
+WHERE a.attrelid = to_regclass(format('%I.%I', $1::text, $2::text))
+  AND a.attnum > 0
+  AND NOT a.attisdropped
+ORDER BY a.attnum
+`
+    rows, err := db.QueryContext(ctx, q, schema, table)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var cols []columnInfo
+    for rows.Next() {
+        var c columnInfo
+        if err := rows.Scan(&c.name, &c.typ, &c.generated, &c.identity); err != nil {
+            return nil, err
+        }
+        cols = append(cols, c)
+    }
+    return cols, rows.Err()
+}
+
+// syncColumns — столбцы таблицы для синхронизации: пересечение main и standin в порядке main
+// с учётом --column-mismatch, без вычисляемых и исключённых столбцов.
+// Если таблицы в standin нет, берутся столбцы main. types — типы столбцов в целевой таблице
+// (standin, а если её нет или столбец только что добавлен — main), в том же порядке.
+func syncColumns(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) (cols, types []string, err error) {
+    mainCols, err := listColumns(snapshotContext(ctx), mainTx, cfg.Schema, tableName)
+    if err != nil {
+        return nil, nil, fmt.Errorf("столбцы main %s: %v", tableName, err)
+    }
+    sSchema, sTable := cfg.StandinSchema, standinTable(cfg, tableName)
+    standinCols, err := listColumns(ctx, standinDB, sSchema, sTable)
+    if err != nil {
+        return nil, nil, fmt.Errorf("столбцы standin %s: %v", sTable, err)
+    }
+    if err := checkExcludedPK(ctx, cfg, mainTx, tableName); err != nil {
+        return nil, nil, err
+    }
+
+    // Свойства столбцов целевой таблицы: standin, а если её ещё нет — main
+    target := standinCols
+    if len(target) == 0 {
+        target = mainCols
+    }
+    standinTypes := make(map[string]string, len(standinCols))
+    generated := make(map[string]bool)
+    var identity []string
+    for _, c := range standinCols {
+        standinTypes[c.name] = c.typ
+    }
+    for _, c := range target {
+        if c.generated {
+            generated[c.name] = true
+        }
+        if c.identity == "a" {
+            identity = append(identity, c.name)
+        }
+    }
+    identityColumns.Lock()
+    identityColumns.m[fmt.Sprintf(`"%s"."%s"`, sSchema, sTable)] = identity
+    identityColumns.Unlock()
+
+    casts := castsFor(cfg, tableName)
+    var missing, skipped []string
+    mainSet := make(map[string]bool, len(mainCols))
+    for _, c := range mainCols {
+        mainSet[c.name] = true
+        if generated[c.name] || isExcluded(cfg, tableName, c.name) {
+            skipped = append(skipped, c.name)
+            continue
+        }
+        if len(standinCols) == 0 {
+            cols, types = append(cols, c.name), append(types, c.typ)
+            continue
+        }
+        sTyp, ok := standinTypes[c.name]
+        if !ok {
+            missing = append(missing, c.name)
+            if cfg.ColumnMismatch != "add" {
+                continue
+            }
+            q := fmt.Sprintf(`ALTER TABLE "%s"."%s" ADD COLUMN IF NOT EXISTS "%s" %s`, sSchema, sTable, c.name, c.typ)
+            if _, err := standinDB.ExecContext(ctx, q); err != nil {
+                return nil, nil, fmt.Errorf("добавление столбца %s.%s в standin: %v", sTable, c.name, err)
+            }
+            logFrom(ctx).Info(T("column added to standin"), "column", c.name, "type", c.typ)
+            sTyp = c.typ
+        } else if sTyp != c.typ {
+            if _, ok := casts[c.name]; !ok {
+                if cfg.ColumnMismatch == "fail" {
+                    return nil, nil, fmt.Errorf("тип столбца %s.%s различается: main %s, standin %s (задайте --column-cast)",
+                        tableName, c.name, c.typ, sTyp)
+                }
+                logFrom(ctx).Warn(T("column types differ, consider --column-cast"),
+                    "column", c.name, "main_type", c.typ, "standin_type", sTyp)
+            }
+        }
+        cols, types = append(cols, c.name), append(types, sTyp)
+    }
+
+    if len(missing) > 0 && cfg.ColumnMismatch != "add" {
+        if cfg.ColumnMismatch == "fail" {
+            return nil, nil, fmt.Errorf("в standin %s нет столбцов main %v (--column-mismatch=fail)", sTable, missing)
+        }
+        logFrom(ctx).Warn(T("columns missing in standin, skipping them"), "columns", missing)
+    }
+    if len(skipped) > 0 {
+        logFrom(ctx).Debug(T("generated and excluded columns skipped"), "columns", skipped)
+    }
+    var extra []string
+    for _, c := range standinCols {
+        if !mainSet[c.name] && !c.generated {
+            extra = append(extra, c.name)
+        }
+    }
+    if len(extra) > 0 {
+        logFrom(ctx).Warn(T("standin has extra columns, leaving them untouched"), "columns", extra)
+    }
+    return cols, types, nil
+}
+
+// checkExcludedPK — столбцы PK исключать нельзя: по ним сопоставляются строки main и standin
+func checkExcludedPK(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    if len(cfg.ExcludeColumns[tableName])+len(cfg.ExcludeColumns["*"]) == 0 {
+        return nil
+    }
+    pkCols, _ := detectPK(ctx, mainTx, cfg.Schema, tableName)
+    for _, c := range pkCols {
+        if isExcluded(cfg, tableName, c) {
+            return fmt.Errorf("столбец %s.%s входит в PK — его нельзя исключать (--exclude-columns)", tableName, c)
+        }
+    }
+    return nil
+}
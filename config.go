@@ -4,23 +4,86 @@ import (
     "flag"
     "log"
     "os"
+    "strings"
     "time"
 )
 
 type Config struct {
-    MainDSN         string        // DSN основной БД
-    StandinDSN      string        // DSN резервной БД
-    SyncSchema      bool          // Синхронизировать структуру?
-    SyncData        bool          // Синхронизировать данные?
-    CleanExtra      bool          // Удалять объекты, отсутствующие в mainDB?
-    FDWMode         bool          // Использовать FDW (foreign data wrapper)?
-    UseUpdatedAt    bool          // Использовать столбец updated_at?
-    ChunkSize       int           // Размер чанка для chunk-based синхронизации
-    Schema          string        // Какую схему синхронизируем
-    Workers         int           // Кол-во потоков для синхронизации таблиц
-    LastSyncTime    time.Time     // Для инкрементальной синхронизации (updated_at > LastSyncTime)
-    PgDumpPath      string        // Путь к pg_dump (если не в PATH)
-    ForcePsqlApply  bool          // Если true, применяем DDL через psql, а не Exec
+    MainDSN               string           // DSN основной БД
+    StandinDSN            string           // DSN резервной БД
+    SyncSchema            bool             // Синхронизировать структуру?
+    SyncData              bool             // Синхронизировать данные?
+    CleanExtra            bool             // Удалять объекты, отсутствующие в mainDB?
+    FDWMode               bool             // Использовать FDW (foreign data wrapper)?
+    UseUpdatedAt          bool             // Использовать столбец updated_at?
+    ChunkSize             int              // Размер чанка для chunk-based синхронизации
+    ChunkMin              int              // Нижняя граница адаптивного размера чанка
+    ChunkMax              int              // Верхняя граница адаптивного размера чанка
+    ChunkTargetDuration   time.Duration    // Целевая длительность одного чанка (0 = не подстраивать по времени)
+    ChunkTargetBytes      int64            // Целевой объём чтения main за чанк (0 = не подстраивать по объёму)
+    Schema                string           // Какую схему синхронизируем
+    StandinSchema         string           // Схема в standin (по умолчанию та же, что Schema)
+    TableMap              tableMap         // Переименования --table-map: таблица main -> таблица standin
+    PartitionMode         string           // Уровень синхронизации секционированных таблиц: leaf | parent
+    DetachedPartitions    string           // Секции standin, отсоединённые в main: detach | drop
+    Workers               int              // Кол-во потоков для синхронизации таблиц
+    ParallelTableRows     int64            // С какой оценки строк таблица делится на части для нескольких воркеров (0 = не делить)
+    MaxRowsPerSec         int64            // Предел чтения строк из main в секунду на весь процесс (0 = без ограничения)
+    MaxBytesPerSec        int64            // Предел чтения из main в байтах в секунду на весь процесс (0 = без ограничения)
+    MaxMainQueries        int              // Сколько запросов чтения main выполняется одновременно (0 = без ограничения)
+    MaxReplicaLag         time.Duration    // Пауза, пока отставание реплик standin больше этого (0 = не проверять)
+    MaxMainConnections    int              // Пауза, пока активных соединений main больше этого (0 = не проверять)
+    ThrottleCheckInterval time.Duration    // Как часто проверять отставание реплик и соединения main
+    LastSyncTime          time.Time        // Для инкрементальной синхронизации (updated_at > LastSyncTime)
+    PgDumpPath            string           // Путь к pg_dump (если не в PATH)
+    ForcePsqlApply        bool             // Если true, применяем DDL через psql, а не Exec
+    IncrementalMode       string           // Инкрементальный режим: "", updated_at, xmin, commit-ts
+    DeleteDetection       string           // Поиск удалений в инкрементальном режиме: none, antijoin, soft
+    SoftDeleteColumn      string           // Столбец мягкого удаления (deleted_at / is_deleted)
+    DeleteCheckInterval   time.Duration    // Как часто выполнять анти-join (0 = каждый запуск)
+    CaptureMode           string           // Режим захвата изменений: "" (выключен) или "trigger"
+    CaptureTables         []string         // Таблицы для trigger-захвата (пусто = все таблицы схемы)
+    Command               string           // Команда: sync (по умолчанию), daemon, uninstall, purge, subset или export
+    Tables                []string         // Синхронизировать только эти таблицы (пусто = все)
+    Jobs                  []string         // Задания daemon-режима: "имя=расписание[|таблицы]"
+    Interval              time.Duration    // Интервал задания по умолчанию в daemon-режиме
+    StatementTimeout      time.Duration    // Таймаут одного SQL-запроса (0 = без ограничения)
+    TableTimeout          time.Duration    // Таймаут синхронизации одной таблицы (0 = без ограничения)
+    RetryAttempts         int              // Сколько раз пробовать применить чанк при временных ошибках (1 = без повторов)
+    RetryBackoff          time.Duration    // Пауза перед первым повтором (дальше удваивается)
+    RetryMaxBackoff       time.Duration    // Верхняя граница паузы между повторами
+    ReportFile            string           // Куда записать JSON-отчёт о проходе (пусто = только лог)
+    Resume                bool             // Продолжать прерванные таблицы с сохранённой точки
+    MetricsAddr           string           // Адрес HTTP-листенера /metrics (пусто = выключен)
+    JobName               string           // Имя задания: ключ блокировки запуска (daemon подставляет имена своих заданий)
+    LockWait              time.Duration    // Сколько ждать блокировку, занятую другим экземпляром (0 = выйти сразу)
+    Transforms            transformRules   // Правила --transform: таблица ("*" — любая) -> столбец -> правило
+    TransformKey          string           // Ключ HMAC для правил hash/pseudonym/email/phone
+    Filters               tableFilters     // Фильтры строк --filter: таблица -> условие WHERE
+    ColumnMismatch        string           // Столбцы main, которых нет в standin: ignore | add | fail
+    ColumnCasts           columnCasts      // Приведения --column-cast: таблица ("*" — любая) -> столбец -> тип
+    ExcludeColumns        columnExclusions // Исключённые столбцы --exclude-columns: таблица ("*" — любая) -> столбцы
+    FilterPurge           bool             // Удалять из standin строки вне фильтра
+    LogFormat             string           // Формат логов: text | json
+    LogLevel              string           // Уровень логов: debug | info | warn | error
+    LogLang               string           // Язык сообщений логов: en | ru
+    Progress              string           // Вывод прогресса: auto | tty | log | off
+    ProgressInterval      time.Duration    // Период строк прогресса в режиме log
+    MaxDeleteRatio        float64          // Максимальная доля удаляемых за проход строк таблицы standin (0 = без ограничения)
+    MaxDropTables         int              // Максимум таблиц, удаляемых clean-extra за проход (0 = без ограничения)
+    AllowEmptyMain        bool             // Разрешить проход при пустой main и непустом standin
+    ProtectedTables       []string         // Таблицы, которые нельзя удалять и очищать
+    CleanMode             string           // Что делать с лишними таблицами: drop | quarantine
+    TrashRetention        time.Duration    // Срок хранения таблиц в карантине (команда purge)
+    SubsetRoot            string           // Корень команды subset: "таблица[=условие]"
+    SubsetLimit           int              // Сколько корневых строк взять в subset (0 = все подходящие)
+    ReloadTables          []string         // Таблицы, которые перезаливаются целиком с подменой (--reload-tables)
+    ReloadLockTimeout     time.Duration    // Сколько ждать блокировку таблицы standin при подмене
+    BulkLoad              string           // Первичная заливка пустых таблиц standin: off | auto
+    ExportDir             string           // Каталог выгрузки команды export
+    ExportFormat          string           // Формат файлов выгрузки: csv | ndjson | parquet
+    ExportCompression     string           // Сжатие файлов выгрузки: none | gzip
+    ExportFileRows        int64            // Максимум строк в одном файле выгрузки
 }
 
 // ParseConfigFromFlags — читает конфигурацию из флагов
@@ -37,17 +100,203 @@ func ParseConfigFromFlags() *Config {
     flag.BoolVar(&cfg.CleanExtra, "clean-extra", false, "Удалять объекты, отсутствующие в mainDB")
     flag.BoolVar(&cfg.FDWMode, "fdw-mode", false, "Использовать ли FDW")
     flag.BoolVar(&cfg.UseUpdatedAt, "use-updated-at", false, "Использовать ли столбец updated_at")
-    flag.IntVar(&cfg.ChunkSize, "chunk-size", 10000, "Размер порции при чанковой синхронизации")
+    flag.StringVar(&cfg.IncrementalMode, "incremental", "", "Инкрементальный режим: updated_at, xmin или commit-ts")
+    flag.StringVar(&cfg.DeleteDetection, "delete-detection", "none", "Поиск удалений для incremental: none, antijoin или soft")
+    flag.StringVar(&cfg.SoftDeleteColumn, "soft-delete-column", "", "Столбец мягкого удаления (deleted_at или is_deleted) для delete-detection=soft")
+    flag.DurationVar(&cfg.DeleteCheckInterval, "delete-check-interval", 0, "Минимальный интервал между анти-join проверками (0 = каждый запуск)")
+    flag.IntVar(&cfg.ChunkSize, "chunk-size", 10000, "Начальный размер порции (ширина диапазона PK) при чанковой синхронизации")
+    flag.IntVar(&cfg.ChunkMin, "chunk-min", 1000, "Нижняя граница адаптивного размера чанка")
+    flag.IntVar(&cfg.ChunkMax, "chunk-max", 1000000, "Верхняя граница адаптивного размера чанка")
+    flag.DurationVar(&cfg.ChunkTargetDuration, "chunk-target-duration", 2*time.Second, "Целевая длительность чанка; размер подстраивается по предыдущим чанкам (0 = не подстраивать)")
+    var chunkTargetBytes string
+    flag.StringVar(&chunkTargetBytes, "chunk-target-bytes", "", "Целевой объём строк main за чанк, например 64MB (пусто = не ограничивать)")
     flag.StringVar(&cfg.Schema, "schema", "public", "Схема для синхронизации")
+    flag.StringVar(&cfg.StandinSchema, "standin-schema", "", "Схема в standin, куда синхронизируется --schema (пусто = та же)")
+    flag.StringVar(&cfg.PartitionMode, "partition-mode", "leaf", "Секционированные таблицы: leaf (секции параллельно) или parent (через корневую таблицу)")
+    flag.StringVar(&cfg.DetachedPartitions, "detached-partitions", "detach", "Секции standin, отсоединённые в main: detach (отсоединить и синхронизировать отдельно) или drop")
+    var tableMapping listFlag
+    flag.Var(&tableMapping, "table-map", "Имя таблицы в standin: main=standin[,main2=standin2]; можно повторять")
     flag.IntVar(&cfg.Workers, "workers", 4, "Число горутин для синхронизации таблиц")
+    flag.Int64Var(&cfg.MaxRowsPerSec, "max-rows-per-sec", 0, "Предел чтения строк из main в секунду на весь процесс (0 = без ограничения)")
+    var maxBytesPerSec string
+    flag.StringVar(&maxBytesPerSec, "max-bytes-per-sec", "", "Предел чтения из main в секунду, например 20MB (пусто = без ограничения)")
+    flag.IntVar(&cfg.MaxMainQueries, "max-main-queries", 0, "Сколько запросов чтения main выполняется одновременно (0 = без ограничения)")
+    flag.DurationVar(&cfg.MaxReplicaLag, "max-replica-lag", 0, "Приостанавливать чанки, пока replay_lag реплик standin больше этого, например 30s (0 = не проверять)")
+    flag.IntVar(&cfg.MaxMainConnections, "max-main-active-connections", 0, "Приостанавливать чанки, пока активных соединений main больше этого (0 = не проверять)")
+    flag.DurationVar(&cfg.ThrottleCheckInterval, "throttle-check-interval", 5*time.Second, "Как часто проверять отставание реплик и соединения main")
+    flag.Int64Var(&cfg.ParallelTableRows, "parallel-table-rows", 10000000, "Таблицы от стольких строк (по оценке) делятся на диапазоны PK и синхронизируются несколькими воркерами (0 = не делить)")
 
     var lastSync string
     flag.StringVar(&lastSync, "last-sync-time", "", "Время последней синхронизации (YYYY-MM-DD HH:MM:SS), если нужно updated_at")
     flag.StringVar(&cfg.PgDumpPath, "pgdump", "pg_dump", "Путь к pg_dump")
     flag.BoolVar(&cfg.ForcePsqlApply, "force-psql", false, "Применять DDL через psql, а не ExecContext")
 
+    var captureTables string
+    flag.StringVar(&cfg.CaptureMode, "capture-mode", "", "Режим захвата изменений: trigger (аудит-триггер + pgsyncer.changelog)")
+    flag.StringVar(&captureTables, "capture-tables", "", "Список таблиц через запятую для trigger-захвата (пусто = все таблицы схемы)")
+
+    var tables string
+    var jobs listFlag
+    flag.StringVar(&tables, "tables", "", "Синхронизировать только эти таблицы (через запятую)")
+    flag.Var(&jobs, "job", "Задание daemon-режима: имя=расписание[|таблица1,таблица2]; можно повторять")
+    flag.DurationVar(&cfg.Interval, "interval", 15*time.Minute, "Интервал задания по умолчанию в daemon-режиме (если нет --job)")
+
+    flag.DurationVar(&cfg.StatementTimeout, "statement-timeout", 0, "Таймаут одного SQL-запроса, например 5m (0 = без ограничения)")
+    flag.DurationVar(&cfg.TableTimeout, "table-timeout", 0, "Таймаут синхронизации одной таблицы, например 1h (0 = без ограничения)")
+    flag.IntVar(&cfg.RetryAttempts, "retry-attempts", 5, "Сколько раз пробовать применить чанк при обрыве соединения, deadlock, конфликте сериализации (1 = без повторов)")
+    flag.DurationVar(&cfg.RetryBackoff, "retry-backoff", 500*time.Millisecond, "Пауза перед первым повтором; дальше удваивается, со случайным разбросом")
+    flag.DurationVar(&cfg.RetryMaxBackoff, "retry-max-backoff", 30*time.Second, "Верхняя граница паузы между повторами")
+    flag.StringVar(&cfg.ReportFile, "report-file", "", "JSON-отчёт о проходе: статус и несинхронизированные чанки и таблицы (пусто = только лог)")
+    flag.BoolVar(&cfg.Resume, "resume", false, "Продолжать прерванные таблицы с места остановки (pgsyncer.sync_state)")
+
+    flag.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "Адрес для Prometheus /metrics, например :9187 (пусто = выключено)")
+    flag.StringVar(&cfg.LogFormat, "log-format", "text", "Формат логов: text или json")
+    flag.StringVar(&cfg.LogLevel, "log-level", "info", "Уровень логов: debug, info, warn, error")
+    flag.StringVar(&cfg.LogLang, "log-lang", "en", "Язык сообщений логов: en или ru (тексты ошибок не переводятся)")
+    flag.StringVar(&cfg.Progress, "progress", "auto", "Вывод прогресса: auto (tty на терминале, иначе log), tty, log, off")
+    flag.DurationVar(&cfg.ProgressInterval, "progress-interval", 30*time.Second, "Период строк прогресса в режиме log")
+    flag.Float64Var(&cfg.MaxDeleteRatio, "max-delete-ratio", 0, "Максимальная доля строк таблицы standin, удаляемых за проход, например 0.1 (0 = без ограничения)")
+    flag.IntVar(&cfg.MaxDropTables, "max-drop-tables", 0, "Максимум таблиц, удаляемых clean-extra за проход (0 = без ограничения)")
+    flag.BoolVar(&cfg.AllowEmptyMain, "allow-empty-main", false, "Разрешить синхронизацию, когда в main нет таблиц, а в standin есть")
+    flag.StringVar(&cfg.CleanMode, "clean-mode", "drop", "Что делать с лишними таблицами при clean-extra: drop или quarantine (перенос в pgsyncer_trash)")
+    flag.DurationVar(&cfg.TrashRetention, "trash-retention", 7*24*time.Hour, "Срок хранения таблиц в pgsyncer_trash для команды purge")
+    flag.StringVar(&cfg.JobName, "job-name", "default", "Имя задания для блокировки запуска (схема + имя задания)")
+    flag.DurationVar(&cfg.LockWait, "lock-wait", 0, "Ждать освобождения блокировки запуска не дольше заданного (0 = выйти с кодом 3)")
+    var transforms listFlag
+    flag.Var(&transforms, "transform", "Правило маскирования таблица.столбец=правило (null, fixed:<v>, hash, pseudonym[:prefix], email, phone, month); можно повторять")
+    flag.StringVar(&cfg.TransformKey, "transform-key", getEnvOrDefault("PGSYNCER_TRANSFORM_KEY", ""), "Ключ HMAC для правил hash/pseudonym/email/phone (или PGSYNCER_TRANSFORM_KEY)")
+    var filters listFlag
+    flag.Var(&filters, "filter", "Фильтр строк таблица=условие, например \"orders=tenant_id = 42\"; можно повторять")
+    flag.BoolVar(&cfg.FilterPurge, "filter-purge", false, "Удалять из standin строки, не проходящие --filter")
+    flag.StringVar(&cfg.SubsetRoot, "subset-root", "", "Корень команды subset: таблица[=условие], например \"customers=country = 'DE'\"")
+    flag.IntVar(&cfg.SubsetLimit, "subset-limit", 1000, "Сколько корневых строк взять в subset (0 = все подходящие)")
+    flag.StringVar(&cfg.ColumnMismatch, "column-mismatch", "ignore", "Столбцы main, которых нет в standin: ignore (пропустить), add (добавить в standin) или fail")
+    var casts listFlag
+    flag.Var(&casts, "column-cast", "Приведение столбца при чтении обеих сторон: таблица.столбец=тип, например \"orders.amount=numeric(12,2)\"; можно повторять")
+    var excludeColumns listFlag
+    flag.Var(&excludeColumns, "exclude-columns", "Не копировать и не сравнивать столбцы: таблица.столбец[,таблица.столбец] (таблица * — любая); можно повторять")
+    var reloadTables string
+    flag.StringVar(&reloadTables, "reload-tables", "", "Таблицы через запятую, которые перезаливаются целиком: копия в staging-таблицу и атомарная подмена")
+    flag.DurationVar(&cfg.ReloadLockTimeout, "reload-lock-timeout", 10*time.Second, "Сколько ждать блокировку таблицы standin при подмене в --reload-tables (0 = без ограничения)")
+    flag.StringVar(&cfg.BulkLoad, "bulk-load", "off", "Первичная заливка пустых таблиц standin: off или auto (индексы строятся после загрузки, триггеры отключаются, затем ANALYZE)")
+    flag.StringVar(&cfg.ExportDir, "export-dir", "", "Каталог для команды export (новый или пустой)")
+    flag.StringVar(&cfg.ExportFormat, "export-format", "csv", "Формат файлов export: csv, ndjson или parquet")
+    flag.StringVar(&cfg.ExportCompression, "export-compression", "none", "Сжатие файлов export: none или gzip (в parquet сжимаются страницы)")
+    flag.Int64Var(&cfg.ExportFileRows, "export-file-rows", 1000000, "Максимум строк в одном файле export; таблица делится на part-00000, part-00001, ...")
+    var protectedTables string
+    flag.StringVar(&protectedTables, "protected-tables", "", "Таблицы через запятую, которые нельзя удалять и очищать в standin")
+
     flag.Parse()
 
+    // Команда — первый позиционный аргумент (sync, daemon, uninstall, purge, subset, export)
+    cfg.Command = flag.Arg(0)
+    if cfg.Command == "" {
+        cfg.Command = "sync"
+    }
+
+    if cfg.Command == "subset" && cfg.SubsetRoot == "" {
+        log.Fatalf("Команде subset нужен --subset-root таблица[=условие]")
+    }
+    if cfg.Command == "export" {
+        if cfg.ExportDir == "" {
+            log.Fatalf("Команде export нужен --export-dir")
+        }
+        switch cfg.ExportFormat {
+        case "csv", "ndjson", "parquet":
+        default:
+            log.Fatalf("Неизвестный export-format: %q (допустимо: csv, ndjson, parquet)", cfg.ExportFormat)
+        }
+        if cfg.ExportCompression != "none" && cfg.ExportCompression != "gzip" {
+            log.Fatalf("Неизвестный export-compression: %q (допустимо: none, gzip)", cfg.ExportCompression)
+        }
+        if cfg.ExportFileRows <= 0 {
+            log.Fatalf("--export-file-rows должен быть больше 0, получено %d", cfg.ExportFileRows)
+        }
+    }
+    if cfg.CaptureMode != "" && cfg.CaptureMode != "trigger" {
+        log.Fatalf("Неизвестный capture-mode: %q (допустимо: trigger)", cfg.CaptureMode)
+    }
+    switch cfg.Progress {
+    case "auto", "tty", "log", "off":
+    default:
+        log.Fatalf("Неизвестный режим progress: %q (допустимо: auto, tty, log, off)", cfg.Progress)
+    }
+    if cfg.ProgressInterval <= 0 {
+        cfg.ProgressInterval = 30 * time.Second
+    }
+    if cfg.MaxDeleteRatio < 0 || cfg.MaxDeleteRatio > 1 {
+        log.Fatalf("max-delete-ratio должен быть в диапазоне [0..1], получено %g", cfg.MaxDeleteRatio)
+    }
+    if cfg.CleanMode != "drop" && cfg.CleanMode != "quarantine" {
+        log.Fatalf("Неизвестный clean-mode: %q (допустимо: drop, quarantine)", cfg.CleanMode)
+    }
+    rules, err := parseTransforms(transforms)
+    if err != nil {
+        log.Fatalf("%v", err)
+    }
+    if needsTransformKey(rules) && cfg.TransformKey == "" {
+        log.Fatalf("Правилам hash/pseudonym/email/phone нужен ключ --transform-key (или PGSYNCER_TRANSFORM_KEY): без ключа хеш PII восстанавливается перебором")
+    }
+    cfg.Transforms = rules
+    if cfg.Filters, err = parseFilters(filters); err != nil {
+        log.Fatalf("%v", err)
+    }
+    if cfg.PartitionMode != "leaf" && cfg.PartitionMode != "parent" {
+        log.Fatalf("Неизвестный partition-mode: %q (допустимо: leaf, parent)", cfg.PartitionMode)
+    }
+    if cfg.DetachedPartitions != "detach" && cfg.DetachedPartitions != "drop" {
+        log.Fatalf("Неизвестный detached-partitions: %q (допустимо: detach, drop)", cfg.DetachedPartitions)
+    }
+    if cfg.ChunkTargetBytes, err = parseByteSize(chunkTargetBytes); err != nil {
+        log.Fatalf("--chunk-target-bytes: %v", err)
+    }
+    if cfg.MaxBytesPerSec, err = parseByteSize(maxBytesPerSec); err != nil {
+        log.Fatalf("--max-bytes-per-sec: %v", err)
+    }
+    if cfg.ChunkMin > 0 && cfg.ChunkMax > 0 && cfg.ChunkMin > cfg.ChunkMax {
+        log.Fatalf("--chunk-min (%d) больше --chunk-max (%d)", cfg.ChunkMin, cfg.ChunkMax)
+    }
+    if cfg.BulkLoad != "off" && cfg.BulkLoad != "auto" {
+        log.Fatalf("Неизвестный bulk-load: %q (допустимо: off, auto)", cfg.BulkLoad)
+    }
+    if cfg.CaptureMode == "trigger" && cfg.PartitionMode == "parent" {
+        log.Fatalf("--capture-mode=trigger работает только с --partition-mode=leaf: журнал ведётся по секциям")
+    }
+    switch cfg.ColumnMismatch {
+    case "ignore", "add", "fail":
+    default:
+        log.Fatalf("Неизвестный column-mismatch: %q (допустимо: ignore, add, fail)", cfg.ColumnMismatch)
+    }
+    if cfg.ColumnCasts, err = parseColumnCasts(casts); err != nil {
+        log.Fatalf("%v", err)
+    }
+    if cfg.ExcludeColumns, err = parseColumnExclusions(excludeColumns); err != nil {
+        log.Fatalf("%v", err)
+    }
+    if cfg.FDWMode && len(cfg.Transforms) > 0 {
+        log.Fatalf("--transform не поддерживается в FDW-режиме: строки копируются на стороне standin, минуя pgsyncer")
+    }
+    if cfg.StandinSchema == "" {
+        cfg.StandinSchema = cfg.Schema
+    }
+    if cfg.TableMap, err = parseTableMap(tableMapping); err != nil {
+        log.Fatalf("%v", err)
+    }
+    cfg.ProtectedTables = splitList(protectedTables)
+    cfg.ReloadTables = splitList(reloadTables)
+    for _, t := range cfg.ReloadTables {
+        if isProtected(cfg, standinTable(cfg, t)) {
+            log.Fatalf("таблица %s одновременно в --reload-tables и --protected-tables: перезаливка удаляет прежнюю таблицу", t)
+        }
+    }
+    cfg.CaptureTables = splitList(captureTables)
+    cfg.Tables = splitList(tables)
+    cfg.Jobs = jobs
+
+    if err := normalizeIncrementalMode(cfg); err != nil {
+        log.Fatalf("%v", err)
+    }
+
     // Если передано lastSyncTime, парсим
     if lastSync != "" {
         t, err := time.Parse("2006-01-02 15:04:05", lastSync)
@@ -67,3 +316,27 @@ func getEnvOrDefault(envKey, def string) string {
     }
     return val
 }
+
+// listFlag — флаг, который можно указать несколько раз (--job a --job b)
+type listFlag []string
+
+func (l *listFlag) String() string {
+    return strings.Join(*l, "; ")
+}
+
+func (l *listFlag) Set(v string) error {
+    *l = append(*l, v)
+    return nil
+}
+
+// splitList — разбивает строку "a, b,c" на срез без пустых элементов
+func splitList(s string) []string {
+    var out []string
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part != "" {
+            out = append(out, part)
+        }
+    }
+    return out
+}
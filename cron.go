@@ -0,0 +1,198 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// schedule — расписание запуска задания в daemon-режиме
+type schedule interface {
+    // Next — ближайший момент запуска строго после t
+    Next(t time.Time) time.Time
+}
+
+// everySchedule — фиксированный интервал (@every 5m)
+type everySchedule struct {
+    interval time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+    return t.Add(s.interval)
+}
+
+// minScheduleInterval — наименьший интервал @every и --interval: при нулевом или
+// отрицательном задание запускалось бы подряд без пауз, нагружая main
+const minScheduleInterval = time.Second
+
+// cronSchedule — классическое cron-выражение из 5 полей: минута час день месяц день_недели
+type cronSchedule struct {
+    minute, hour, dom, month, dow uint64 // битовые маски допустимых значений
+    domStar, dowStar             bool   // поле задано как '*' (важно для правила OR дня месяца/недели)
+}
+
+// parseSchedule — разбирает "@every <duration>", "@hourly", "@daily" или cron из 5 полей.
+func parseSchedule(expr string) (schedule, error) {
+    expr = strings.TrimSpace(expr)
+    switch {
+    case strings.HasPrefix(expr, "@every "):
+        d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+        if err != nil {
+            return nil, fmt.Errorf("неверный интервал в %q: %v", expr, err)
+        }
+        if d < minScheduleInterval {
+            return nil, fmt.Errorf("слишком маленький интервал в %q", expr)
+        }
+        return everySchedule{interval: d}, nil
+    case expr == "@hourly":
+        expr = "0 * * * *"
+    case expr == "@daily":
+        expr = "0 0 * * *"
+    }
+
+    fields := strings.Fields(expr)
+    if len(fields) != 5 {
+        return nil, fmt.Errorf("cron-выражение %q должно содержать 5 полей", expr)
+    }
+
+    s := &cronSchedule{}
+    var err error
+    if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+        return nil, err
+    }
+    if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+        return nil, err
+    }
+    if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+        return nil, err
+    }
+    if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+        return nil, err
+    }
+    if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+        return nil, err
+    }
+    // 7 — тоже воскресенье
+    if s.dow&(1<<7) != 0 {
+        s.dow |= 1
+    }
+    s.domStar = fields[2] == "*"
+    s.dowStar = fields[4] == "*"
+    if !s.satisfiable() {
+        return nil, fmt.Errorf("cron-выражение %q никогда не срабатывает: таких дней нет ни в одном месяце", expr)
+    }
+    return s, nil
+}
+
+// cronMaxDays — наибольшее число дней в месяце (февраль — с учётом високосных лет)
+var cronMaxDays = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// satisfiable — есть ли хоть один день, подходящий под поля дня месяца, месяца и дня недели.
+// Минуты и часы всегда непусты, а любой день недели встречается в каждом месяце,
+// поэтому отказать может только день месяца, которого нет в выбранных месяцах (30 2 *).
+func (s *cronSchedule) satisfiable() bool {
+    if s.domStar || !s.dowStar {
+        return true
+    }
+    for m := 1; m <= 12; m++ {
+        if s.month&(1<<uint(m)) == 0 {
+            continue
+        }
+        for d := 1; d <= cronMaxDays[m]; d++ {
+            if s.dom&(1<<uint(d)) != 0 {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// parseCronField — поле cron: "*", "*/n", "a", "a-b", "a-b/n" и списки через запятую.
+func parseCronField(field string, min, max int) (uint64, error) {
+    var mask uint64
+    for _, part := range strings.Split(field, ",") {
+        step := 1
+        if i := strings.Index(part, "/"); i >= 0 {
+            n, err := strconv.Atoi(part[i+1:])
+            if err != nil || n < 1 {
+                return 0, fmt.Errorf("неверный шаг в поле cron %q", field)
+            }
+            step = n
+            part = part[:i]
+        }
+
+        lo, hi := min, max
+        switch {
+        case part == "*":
+        case strings.Contains(part, "-"):
+            bounds := strings.SplitN(part, "-", 2)
+            a, err1 := strconv.Atoi(bounds[0])
+            b, err2 := strconv.Atoi(bounds[1])
+            if err1 != nil || err2 != nil {
+                return 0, fmt.Errorf("неверный диапазон в поле cron %q", field)
+            }
+            lo, hi = a, b
+        default:
+            a, err := strconv.Atoi(part)
+            if err != nil {
+                return 0, fmt.Errorf("неверное значение в поле cron %q", field)
+            }
+            lo, hi = a, a
+        }
+        if lo < min || hi > max || lo > hi {
+            return 0, fmt.Errorf("значение поля cron %q вне диапазона [%d..%d]", field, min, max)
+        }
+        for v := lo; v <= hi; v += step {
+            mask |= 1 << uint(v)
+        }
+    }
+    return mask, nil
+}
+
+// cronSearchDays — сколько дней вперёд ищет Next. Реже всех срабатывает 29 февраля:
+// между високосными годами бывает 8 лет (2096 → 2104), поэтому ищем чуть дальше.
+const cronSearchDays = 9 * 366
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+    t = t.Truncate(time.Minute).Add(time.Minute)
+    loc := t.Location()
+    // Сначала подходящий день, затем в нём — первые подходящие час и минуту
+    for i := 0; i < cronSearchDays; i++ {
+        day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, loc)
+        if !s.dayMatches(day) {
+            continue
+        }
+        for h := 0; h < 24; h++ {
+            if s.hour&(1<<uint(h)) == 0 {
+                continue
+            }
+            for m := 0; m < 60; m++ {
+                if s.minute&(1<<uint(m)) == 0 {
+                    continue
+                }
+                c := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, loc)
+                // Минуты, выпавшие при переходе на летнее время, пропускаем
+                if c.Before(t) || c.Hour() != h || c.Minute() != m {
+                    continue
+                }
+                return c
+            }
+        }
+    }
+    return time.Time{}
+}
+
+// dayMatches — подходит ли день t под поля месяца, дня месяца и дня недели
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+    if s.month&(1<<uint(t.Month())) == 0 {
+        return false
+    }
+    domOK := s.dom&(1<<uint(t.Day())) != 0
+    dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+    // Как в cron: если заданы оба поля дня — достаточно совпадения любого
+    if !s.domStar && !s.dowStar {
+        return domOK || dowOK
+    }
+    return domOK && dowOK
+}
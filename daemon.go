@@ -0,0 +1,141 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+)
+
+// daemonJob — задание daemon-режима: расписание и (опционально) группа таблиц
+type daemonJob struct {
+    name   string
+    expr   string
+    sched  schedule
+    tables []string
+}
+
+// parseJobs — разбирает --job "имя=расписание[|таблица1,таблица2]".
+// Без --job создаётся одно задание default с интервалом --interval.
+func parseJobs(cfg *Config) ([]daemonJob, error) {
+    if len(cfg.Jobs) == 0 {
+        if cfg.Interval < minScheduleInterval {
+            return nil, fmt.Errorf("слишком маленький --interval=%v (минимум %v)", cfg.Interval, minScheduleInterval)
+        }
+        return []daemonJob{{
+            name:  "default",
+            expr:  fmt.Sprintf("@every %v", cfg.Interval),
+            sched: everySchedule{interval: cfg.Interval},
+        }}, nil
+    }
+
+    seen := make(map[string]bool)
+    var jobs []daemonJob
+    for _, spec := range cfg.Jobs {
+        eq := strings.Index(spec, "=")
+        if eq <= 0 {
+            return nil, fmt.Errorf("неверное описание задания %q (ожидается имя=расписание[|таблицы])", spec)
+        }
+        j := daemonJob{name: strings.TrimSpace(spec[:eq])}
+        rest := spec[eq+1:]
+        if bar := strings.Index(rest, "|"); bar >= 0 {
+            j.tables = splitList(rest[bar+1:])
+            rest = rest[:bar]
+        }
+        j.expr = strings.TrimSpace(rest)
+
+        sched, err := parseSchedule(j.expr)
+        if err != nil {
+            return nil, fmt.Errorf("задание %s: %v", j.name, err)
+        }
+        j.sched = sched
+
+        if seen[j.name] {
+            return nil, fmt.Errorf("задание %s описано дважды", j.name)
+        }
+        seen[j.name] = true
+        jobs = append(jobs, j)
+    }
+    return jobs, nil
+}
+
+// runDaemon — долгоживущий режим: каждое задание крутится в своей горутине по расписанию,
+// пулы соединений mainDB/standinDB остаются открытыми между запусками.
+// SIGTERM/SIGINT отменяют ctx: новые запуски не начинаются, текущие дорабатывают текущий чанк.
+func runDaemon(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "daemon")
+    jobs, err := parseJobs(cfg)
+    if err != nil {
+        return err
+    }
+
+    // Блокировки всех заданий берутся до первого запуска и держатся всё время работы демона
+    for _, j := range jobs {
+        lock, err := acquireRunLock(ctx, cfg, j.name)
+        if err != nil {
+            return err
+        }
+        defer lock.release()
+    }
+
+    var wg sync.WaitGroup
+    for _, j := range jobs {
+        wg.Add(1)
+        go func(j daemonJob) {
+            defer wg.Done()
+            runJobLoop(withLogAttrs(ctx, "job", j.name), cfg, j)
+        }(j)
+    }
+    logFrom(ctx).Info(T("daemon started"), "jobs", len(jobs))
+
+    wg.Wait()
+    logFrom(ctx).Info(T("all jobs stopped"))
+    return nil
+}
+
+// runJobLoop — цикл одного задания. Запуски одного задания идут строго последовательно,
+// поэтому перекрыться не могут: если запуск затянулся, пропущенные срабатывания
+// не догоняются, а следующее считается от момента окончания.
+func runJobLoop(ctx context.Context, cfg *Config, j daemonJob) {
+    for {
+        next := j.sched.Next(time.Now())
+        if next.IsZero() {
+            logFrom(ctx).Warn(T("schedule has no next run, job stopped"), "schedule", j.expr)
+            return
+        }
+        logFrom(ctx).Info(T("next run scheduled"), "at", next.Format("2006-01-02 15:04:05"))
+
+        timer := time.NewTimer(time.Until(next))
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return
+        case <-timer.C:
+        }
+
+        jobCfg := *cfg
+        jobCfg.JobName = j.name
+        if len(j.tables) > 0 {
+            jobCfg.Tables = j.tables
+        }
+
+        started := time.Now()
+        logFrom(ctx).Info(T("job started"))
+        err := runSync(ctx, &jobCfg)
+        switch {
+        case errors.Is(err, errShutdown):
+            logFrom(ctx).Info(T("job stopped by signal"), "duration", time.Since(started).Round(time.Millisecond))
+            return
+        case err != nil:
+            logFrom(ctx).Error(T("job failed"), "duration", time.Since(started).Round(time.Millisecond), "error", err)
+        default:
+            logFrom(ctx).Info(T("job finished"), "duration", time.Since(started).Round(time.Millisecond))
+        }
+
+        if ctx.Err() != nil {
+            return
+        }
+    }
+}
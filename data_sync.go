@@ -3,84 +3,252 @@ package main
 import (
     "context"
     "database/sql"
+    "errors"
     "fmt"
-    "log"
     "sync"
+    "time"
 )
 
 // SyncData — основной процесс синхронизации данных
-func SyncData(cfg *Config) error {
-    log.Println("[Data] Начало синхронизации данных...")
+func SyncData(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "data", "schema", cfg.Schema)
+    logFrom(ctx).Info(T("data sync started"))
 
     // 1) Открываем транзакцию REPEATABLE READ в mainDB (для «моментального среза»).
     txOpts := &sql.TxOptions{
         Isolation: sql.LevelRepeatableRead,
         ReadOnly:  false,
     }
-    mainTx, err := mainDB.BeginTx(context.Background(), txOpts)
+    // Снимок не обрывают ни остановка, ни таймауты: остановка проверяется между чанками (см. snapshotContext)
+    mainTx, err := mainDB.BeginTx(snapshotContext(ctx), txOpts)
     if err != nil {
         return fmt.Errorf("BeginTx mainDB: %v", err)
     }
     defer mainTx.Rollback()
+    if err := disableStatementTimeout(ctx, mainTx); err != nil {
+        return err
+    }
+
+    if cfg.IncrementalMode == "commit-ts" {
+        if err := checkCommitTimestamps(ctx, mainTx); err != nil {
+            return err
+        }
+    }
+    // pgsyncer.sync_state: горизонты incremental, анти-join и точки остановки прерванных запусков
+    if err := ensureSyncState(ctx); err != nil {
+        return err
+    }
+    // Индексы и триггеры, снятые прерванной первичной заливкой, возвращаются до начала прохода
+    if err := ensureBulkLoadState(ctx); err != nil {
+        return err
+    }
+    if err := restoreBulkLoads(ctx, cfg); err != nil {
+        return err
+    }
 
     // 2) Получаем список таблиц в main
-    mainTables, err := listTables(mainTx, cfg.Schema)
+    mainTables, err := listTables(snapshotContext(ctx), mainTx, cfg.Schema)
     if err != nil {
         return fmt.Errorf("listTables(mainDB): %v", err)
     }
-    log.Printf("[Data] Найдено %d таблиц в схеме %q основной БД", len(mainTables), cfg.Schema)
+    logFrom(ctx).Info(T("tables found in main"), "tables", len(mainTables))
+
+    standinTables, err := listTables(ctx, standinDB, cfg.StandinSchema)
+    if err != nil {
+        return fmt.Errorf("listTables(standinDB): %v", err)
+    }
+    if err := checkEmptyMain(cfg, mainTables, standinTables); err != nil {
+        return err
+    }
     if len(mainTables) == 0 {
-        log.Println("[Data] В основной БД нет таблиц для обработки, выходим.")
+        logFrom(ctx).Info(T("no tables in main, nothing to do"))
         if err := mainTx.Commit(); err != nil {
             return fmt.Errorf("Commit mainTx (no tables): %v", err)
         }
         return nil
     }
 
-    // 3) Если нужно, удаляем «лишние» таблицы в standinDB
-    standinTables, err := listTables(standinDB, cfg.Schema)
+    // Секции: новые в main создаются в standin, отсоединённые — по --detached-partitions
+    mainParts, err := loadPartitions(snapshotContext(ctx), mainTx, cfg.Schema)
     if err != nil {
-        return fmt.Errorf("listTables(standinDB): %v", err)
+        return fmt.Errorf("loadPartitions(mainDB): %v", err)
+    }
+    skipTables, err := syncPartitions(ctx, cfg, mainParts, mainTables)
+    if err != nil {
+        return err
     }
+
+    // 3) Если нужно, удаляем «лишние» таблицы в standinDB
     if cfg.CleanExtra {
-        log.Println("[Data] Удаление лишних таблиц включено (clean-extra).")
-        if err := dropExtraTables(standinTables, mainTables, cfg.Schema); err != nil {
+        logFrom(ctx).Info(T("dropping extra standin tables (clean-extra)"))
+        if err := dropExtraTables(ctx, cfg, standinTables, mainTables); err != nil {
             return err
         }
     }
 
+    // Ограничение списком --tables (или группой таблиц задания daemon-режима).
+    // dropExtraTables выше получает полный список, иначе удалил бы «невыбранные» таблицы.
+    if len(cfg.Tables) > 0 {
+        var selected []string
+        for _, t := range mainTables {
+            if inSlice(cfg.Tables, t) {
+                selected = append(selected, t)
+            }
+        }
+        logFrom(ctx).Info(T("tables selected"), "job", cfg.JobName, "selected", len(selected), "tables", len(mainTables))
+        mainTables = selected
+    }
+
+    // Секционированные таблицы синхронизируются на одном уровне: секциями или через корень
+    var syncTables []string
+    for _, t := range mainParts.selectLevel(mainTables, cfg.PartitionMode) {
+        if !skipTables[t] {
+            syncTables = append(syncTables, t)
+        }
+    }
+    mainTables = syncTables
+
+    // Горизонт incremental считывается на mainTx до экспорта снимка: now() транзакций
+    // воркеров позже снимка (см. readSnapshotInfo)
+    if cfg.IncrementalMode == "xmin" || cfg.IncrementalMode == "commit-ts" {
+        snap, err := readSnapshotInfo(ctx, mainTx)
+        if err != nil {
+            return err
+        }
+        ctx = withSnapshotInfo(ctx, snap)
+    }
+
+    // Снимок mainTx для транзакций воркеров: каждый воркер читает main по своему соединению
+    snapshot, err := exportSnapshot(ctx, mainTx)
+    if err != nil {
+        return err
+    }
+
+    // Оценка размеров таблиц для прогресса, ETA и деления больших таблиц (по статистике pg_class, без COUNT(*))
+    var estimates map[string]int64
+    if cfg.Progress != "off" || cfg.ParallelTableRows > 0 {
+        estimates, err = estimateTableRows(ctx, mainTx, cfg.Schema)
+        if err != nil {
+            logFrom(ctx).Warn(T("table size estimate failed, progress without ETA"), "error", err)
+        }
+        if cfg.PartitionMode == "parent" {
+            estimates = mainParts.rollupEstimates(estimates)
+        }
+    }
+    if cfg.Progress != "off" {
+        tracker := newProgressTracker(mainTables, estimates)
+        ctx = withProgress(ctx, tracker)
+
+        reportCtx, stopReport := context.WithCancel(ctx)
+        defer stopReport()
+        go runProgressReporter(reportCtx, tracker, progressMode(cfg), cfg.ProgressInterval)
+    }
+
     // 4) Готовим worker pool для параллельной обработки
     workerCount := cfg.Workers
     if workerCount < 1 {
         workerCount = 1
     }
-    log.Printf("[Data] Запускаем %d воркеров для синхронизации %d таблиц", workerCount, len(mainTables))
 
-    // Канал с названиями таблиц
-    tableCh := make(chan string, len(mainTables))
+    // Очередь: сначала части больших таблиц (они дольше всего), затем таблицы целиком
+    var items, whole []workItem
+    var runs []*tableRun
     for _, t := range mainTables {
-        tableCh <- t
+        run, err := planTableRun(ctx, cfg, mainTx, t, estimates[t])
+        if err != nil {
+            return err
+        }
+        if run == nil {
+            whole = append(whole, workItem{table: t})
+            continue
+        }
+        runs = append(runs, run)
+        for i := range run.parts {
+            items = append(items, workItem{table: t, run: run, part: i})
+        }
     }
-    close(tableCh)
+    items = append(items, whole...)
+    logFrom(ctx).Info(T("starting workers"), "workers", workerCount, "tables", len(mainTables))
+
+    // Канал с таблицами и частями таблиц
+    itemCh := make(chan workItem, len(items))
+    for _, it := range items {
+        itemCh <- it
+    }
+    close(itemCh)
 
     var wg sync.WaitGroup
     errCh := make(chan error, workerCount)
 
+    // Какие таблицы успели пройти — для отчёта о прерванном запуске
+    var doneMu sync.Mutex
+    done := make(map[string]bool, len(mainTables))
+
+    // Транзакции воркеров фиксируются вместе, после всех таблиц
+    var txMu sync.Mutex
+    var workerTxs []*sql.Tx
+    defer func() {
+        for _, tx := range workerTxs {
+            tx.Rollback()
+        }
+    }()
+
     // Запускаем воркеры
     for i := 0; i < workerCount; i++ {
         wg.Add(1)
         go func(workerID int) {
             defer wg.Done()
-            for tbl := range tableCh {
-                log.Printf("[Worker %d] Начало syncTableData для таблицы %s", workerID, tbl)
-                if err := syncTableData(cfg, mainTx, tbl); err != nil {
-                    log.Printf("[Worker %d] Ошибка syncTableData(%s): %v", workerID, tbl, err)
+            wctx := withLogAttrs(ctx, "worker", workerID)
+            workerTx, err := beginSnapshotTx(ctx, snapshot)
+            if err != nil {
+                errCh <- err
+                return
+            }
+            txMu.Lock()
+            workerTxs = append(workerTxs, workerTx)
+            txMu.Unlock()
+
+            for item := range itemCh {
+                if err := checkStop(ctx); err != nil {
+                    errCh <- err
+                    return
+                }
+                if item.run != nil {
+                    // Часть большой таблицы: подготовку и завершение таблицы делает tableRun
+                    metricWorkers.Add("", 1)
+                    last, err := item.run.syncPart(ctx, workerTx, workerID, item.part)
+                    metricWorkers.Add("", -1)
+                    if err != nil {
+                        if !errors.Is(err, errShutdown) {
+                            logFrom(wctx).Error(T("table sync failed"), "table", item.table, "error", err)
+                        }
+                        errCh <- err
+                        return
+                    }
+                    if last {
+                        doneMu.Lock()
+                        done[item.table] = true
+                        doneMu.Unlock()
+                    }
+                    continue
+                }
+
+                tbl := item.table
+                tctx := withLogAttrs(wctx, "table", tbl)
+                logFrom(tctx).Info(T("table sync started"))
+                progressFrom(tctx).startTable(tbl)
+                if err := syncTableWithTimeout(tctx, cfg, workerTx, tbl); err != nil {
+                    logFrom(tctx).Error(T("table sync failed"), "error", err)
+                    reportFrom(tctx).tableFailed(tbl, err)
                     errCh <- err
                     // Выходим из воркера, чтобы не продолжать
                     return
                 }
+                doneMu.Lock()
+                done[tbl] = true
+                doneMu.Unlock()
             }
-            log.Printf("[Worker %d] Завершение воркера (таблицы закончились).", workerID)
+            logFrom(wctx).Debug(T("worker finished, no more tables"))
         }(i + 1)
     }
 
@@ -88,24 +256,62 @@ func SyncData(cfg *Config) error {
     wg.Wait()
     close(errCh)
 
-    // Смотрим, были ли ошибки
+    // Части, оставшиеся в очереди после остановки или ошибки: индексы --bulk-load
+    // возвращаются, точка --resume сохраняется
+    for _, run := range runs {
+        run.abort()
+    }
+
+    // Смотрим, были ли ошибки. При остановке снимок не коммитим:
+    // так записи журнала trigger-захвата останутся до следующего запуска.
+    var firstErr error
     for e := range errCh {
-        if e != nil {
-            // Прерываемся на первой попавшейся ошибке.
-            return e
+        if e != nil && firstErr == nil {
+            firstErr = e
+        }
+    }
+    if firstErr == nil && ctx.Err() != nil {
+        firstErr = checkStop(ctx)
+    }
+    if firstErr != nil {
+        if ctx.Err() != nil {
+            var pending []string
+            for _, t := range mainTables {
+                if !done[t] {
+                    pending = append(pending, t)
+                }
+            }
+            logFrom(ctx).Warn(T("run interrupted"), "done", len(done), "tables", len(mainTables), "pending", pending)
+            return checkStop(ctx)
         }
+        // Прерываемся на первой попавшейся ошибке.
+        return firstErr
     }
 
-    // Если дошли сюда — значит все воркеры закончили без ошибок
+    // Если дошли сюда — значит все воркеры закончили без ошибок.
+    // mainTx фиксируется последней: пока она открыта, снимок воркеров действителен.
+    for _, tx := range workerTxs {
+        if err := tx.Commit(); err != nil {
+            return fmt.Errorf("Commit worker tx: %v", err)
+        }
+    }
     if err := mainTx.Commit(); err != nil {
         return fmt.Errorf("Commit mainTx: %v", err)
     }
-    log.Println("[Data] Синхронизация данных успешно завершена (все таблицы).")
+    // Время успеха — только для зафиксированных изменений и только у таблиц без пропущенных
+    // чанков: иначе алерт на отставание молчал бы, хотя часть строк не синхронизирована
+    now := float64(time.Now().Unix())
+    for t := range done {
+        if !reportFrom(ctx).hasFailures(t) {
+            metricLastSuccess.Set(t, now)
+        }
+    }
+    logFrom(ctx).Info(T("data sync finished"), "tables", len(mainTables))
     return nil
 }
 
 // listTables — возвращает список таблиц (table_name) из information_schema.tables для заданной схемы
-func listTables(db interface {
+func listTables(ctx context.Context, db interface {
     QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }, schema string) ([]string, error) {
     q := `
@@ -115,7 +321,7 @@ WHERE table_schema = $1
   AND table_type = 'BASE TABLE'
 ORDER BY table_name;
 `
-    rows, err := db.QueryContext(context.Background(), q, schema)
+    rows, err := db.QueryContext(ctx, q, schema)
     if err != nil {
         return nil, err
     }
@@ -133,20 +339,83 @@ ORDER BY table_name;
 }
 
 // dropExtraTables — удаляет в standinDB те таблицы, которых нет в mainDB
-func dropExtraTables(standinTables, mainTables []string, schema string) error {
+// (или переносит их в карантин при --clean-mode=quarantine).
+// План удаления целиком проверяется защитными порогами до первого DROP.
+// Таблицы main сравниваются с standin под именами standin (--table-map).
+func dropExtraTables(ctx context.Context, cfg *Config, standinTables, mainTables []string) error {
     mainSet := make(map[string]bool, len(mainTables))
     for _, t := range mainTables {
-        mainSet[t] = true
+        mainSet[standinTable(cfg, t)] = true
     }
+    var toDrop []string
     for _, t := range standinTables {
         if !mainSet[t] {
-            dropSQL := fmt.Sprintf(`DROP TABLE IF EXISTS "%s"."%s" CASCADE;`, schema, t)
-            if _, err := standinDB.Exec(dropSQL); err != nil {
-                log.Printf("[CLEAN] Не смогли удалить лишнюю таблицу %s: %v", t, err)
+            toDrop = append(toDrop, t)
+        }
+    }
+    if err := checkDropAllowed(cfg, toDrop); err != nil {
+        return err
+    }
+    for _, t := range toDrop {
+        reportDependents(ctx, cfg.StandinSchema, t)
+        if cfg.CleanMode == "quarantine" {
+            name, err := quarantineTable(ctx, cfg.StandinSchema, t)
+            if err != nil {
+                logFrom(ctx).Warn(T("failed to quarantine extra table"), "table", t, "error", err)
             } else {
-                log.Printf("[CLEAN] Удалена таблица %s, которой нет в mainDB", t)
+                logFrom(ctx).Info(T("extra table moved to quarantine"), "table", t, "trash_table", trashSchema+"."+name)
             }
+            continue
+        }
+        dropSQL := fmt.Sprintf(`DROP TABLE IF EXISTS "%s"."%s" CASCADE;`, cfg.StandinSchema, t)
+        if _, err := standinDB.Exec(dropSQL); err != nil {
+            logFrom(ctx).Warn(T("failed to drop extra table"), "table", t, "error", err)
+        } else {
+            logFrom(ctx).Info(T("dropped table missing in main"), "table", t)
         }
     }
     return nil
 }
+
+// syncTableWithTimeout — syncTableData с ограничением времени на таблицу (--table-timeout).
+// Здесь же снимаются метрики уровня таблицы: длительность и активные воркеры
+// (время успеха — только после фиксации транзакций, в конце SyncData).
+func syncTableWithTimeout(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    if cfg.TableTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, cfg.TableTimeout)
+        defer cancel()
+    }
+
+    ctx = withDeleteGuard(ctx, cfg, tableName)
+
+    metricWorkers.Add("", 1)
+    defer metricWorkers.Add("", -1)
+
+    started := time.Now()
+    bl, err := beginBulkLoad(ctx, cfg, tableName)
+    if err == nil {
+        err = syncTableData(ctx, cfg, mainTx, tableName)
+    }
+    if err == nil {
+        err = purgeOutsideFilter(ctx, cfg, tableName)
+    }
+    if bl != nil {
+        // Индексы и триггеры возвращаются и после ошибки или остановки
+        if ferr := bl.finish(applyContext(ctx), cfg.Workers); ferr != nil {
+            if err == nil {
+                err = ferr
+            } else {
+                logFrom(ctx).Warn(T("bulk load restore failed"), "table", tableName, "error", ferr)
+            }
+        }
+    }
+    observeSince(metricTableDuration, tableName, started)
+    if err != nil {
+        metricErrors.Add("data", 1)
+        return err
+    }
+    progressFrom(ctx).finishTable(tableName)
+    logFrom(ctx).Info(T("table sync finished"), "duration", time.Since(started).Round(time.Millisecond))
+    return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// detectDeletes — шаг поиска удалённых строк после инкрементальной синхронизации таблицы.
+// Режимы (cfg.DeleteDetection):
+//   - none     — удаления не переносятся;
+//   - soft     — помеченные soft-delete строки удаляются прямо в syncRowsWhere;
+//   - antijoin — периодический анти-join по PK между main и standin (см. antiJoinDeletes).
+func detectDeletes(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    if cfg.DeleteDetection != "antijoin" {
+        return nil
+    }
+
+    pkCols, _ := detectPK(ctx, mainTx, cfg.Schema, tableName)
+    if len(pkCols) == 0 {
+        logFrom(ctx).Warn(T("table has no PK, anti-join impossible, skipping"))
+        return nil
+    }
+
+    // Анти-join читает обе таблицы целиком, поэтому запускаем его не чаще DeleteCheckInterval
+    if cfg.DeleteCheckInterval > 0 {
+        last, err := loadLastDeleteCheck(ctx, cfg.StandinSchema, standinTable(cfg, tableName))
+        if err != nil {
+            return fmt.Errorf("loadLastDeleteCheck(%s): %v", tableName, err)
+        }
+        if last.Valid && time.Since(last.Time) < cfg.DeleteCheckInterval {
+            logFrom(ctx).Debug(T("anti-join ran recently, skipping"),
+                "ago", time.Since(last.Time).Round(time.Second), "interval", cfg.DeleteCheckInterval)
+            return nil
+        }
+    }
+
+    deleted, err := antiJoinDeletes(ctx, cfg, mainTx, tableName, pkCols)
+    if err != nil {
+        return fmt.Errorf("[Deletes] анти-join %s: %v", tableName, err)
+    }
+    reportDeletes(ctx, "antijoin", deleted)
+
+    return saveLastDeleteCheck(ctx, cfg.StandinSchema, standinTable(cfg, tableName), cfg.IncrementalMode)
+}
+
+// antiJoinDeletes — постранично (keyset по PK, cfg.ChunkSize ключей) читает из standinDB
+// только столбцы PK, проверяет их наличие в снимке mainTx и удаляет отсутствующие.
+func antiJoinDeletes(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string, pkCols []string) (int, error) {
+    schema := cfg.Schema
+    sSchema, sTable := cfg.StandinSchema, standinTable(cfg, tableName)
+
+    pkList := quoteColumns(pkCols)
+    keyCols := make([]string, len(pkCols))
+    existConds := make([]string, len(pkCols))
+    for i, c := range pkCols {
+        keyCols[i] = fmt.Sprintf(`"%s"`, c)
+        existConds[i] = fmt.Sprintf(`t."%[1]s" = (jsonb_populate_record(NULL::"%[2]s"."%[3]s", k))."%[1]s"`, c, schema, tableName)
+    }
+
+    // Страница ключей standin: WHERE (pk) > (последний ключ прошлой страницы).
+    // Строки standin вне --filter не проверяются: они не синхронизируются и удалёнными не считаются.
+    filter := filterCond(cfg, tableName)
+    pageFirst := fmt.Sprintf(`SELECT to_jsonb(p)::text FROM (SELECT %s FROM "%s"."%s" WHERE %s ORDER BY %s LIMIT $1) p`,
+        pkList, sSchema, sTable, filter, pkList)
+    pageNext := fmt.Sprintf(`
+SELECT to_jsonb(p)::text FROM (
+    SELECT %[1]s FROM "%[2]s"."%[3]s"
+    WHERE (%[1]s) > (SELECT %[1]s FROM jsonb_populate_record(NULL::"%[2]s"."%[3]s", $2::jsonb))
+      AND %[4]s
+    ORDER BY %[1]s LIMIT $1
+) p`, pkList, sSchema, sTable, filter)
+
+    // Какие из ключей страницы отсутствуют в main (фильтр здесь не нужен: строка, которая
+    // есть в main, но вышла из фильтра, не удалена)
+    missingQ := fmt.Sprintf(`
+SELECT k::text FROM jsonb_array_elements($1::jsonb) k
+WHERE NOT EXISTS (SELECT 1 FROM "%s"."%s" t WHERE %s)`,
+        schema, tableName, strings.Join(existConds, " AND "))
+
+    pageSize := cfg.ChunkSize
+    if pageSize < 1 {
+        pageSize = 10000
+    }
+
+    deleted := 0
+    lastKey := ""
+    for {
+        var rows *sql.Rows
+        var err error
+        if lastKey == "" {
+            rows, err = standinDB.QueryContext(ctx, pageFirst, pageSize)
+        } else {
+            rows, err = standinDB.QueryContext(ctx, pageNext, pageSize, lastKey)
+        }
+        if err != nil {
+            return deleted, fmt.Errorf("чтение ключей standin: %v", err)
+        }
+        var keys []string
+        for rows.Next() {
+            var k string
+            if err := rows.Scan(&k); err != nil {
+                rows.Close()
+                return deleted, err
+            }
+            keys = append(keys, k)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return deleted, err
+        }
+        if len(keys) == 0 {
+            break
+        }
+        lastKey = keys[len(keys)-1]
+        metricRowsCompared.Add(tableName, float64(len(keys)))
+
+        missing, err := queryStrings(snapshotContext(ctx), mainTx, missingQ, "["+strings.Join(keys, ",")+"]")
+        if err != nil {
+            return deleted, fmt.Errorf("проверка ключей в main: %v", err)
+        }
+        if len(missing) > 0 {
+            if err := applyKeyedBatch(ctx, sSchema, sTable, nil, pkCols, nil, missing); err != nil {
+                return deleted, err
+            }
+            deleted += len(missing)
+            metricRowsDeleted.Add(tableName, float64(len(missing)))
+        }
+
+        if len(keys) < pageSize {
+            break
+        }
+        if err := checkStop(ctx); err != nil {
+            return deleted, err
+        }
+    }
+    return deleted, nil
+}
+
+// reportDeletes — единый формат отчёта об удалённых строках
+func reportDeletes(ctx context.Context, mode string, deleted int) {
+    logFrom(ctx).Info(T("rows deleted"), "mode", mode, "deleted", deleted)
+}
+
+// softDeleteExpr — SQL-признак мягкого удаления: для boolean-столбца (is_deleted) — IS TRUE,
+// для остальных (deleted_at и т.п.) — IS NOT NULL.
+func softDeleteExpr(ctx context.Context, mainTx *sql.Tx, schema, table, column string) (string, error) {
+    q := `
+SELECT data_type
+FROM information_schema.columns
+WHERE table_schema = $1
+  AND table_name = $2
+  AND column_name = $3
+`
+    var dataType string
+    err := mainTx.QueryRowContext(snapshotContext(ctx), q, schema, table, column).Scan(&dataType)
+    if err == sql.ErrNoRows {
+        return "", fmt.Errorf("в таблице %s нет soft-delete столбца %q", table, column)
+    }
+    if err != nil {
+        return "", err
+    }
+    if dataType == "boolean" {
+        return fmt.Sprintf(`("%s" IS TRUE)`, column), nil
+    }
+    return fmt.Sprintf(`("%s" IS NOT NULL)`, column), nil
+}
+
+// pkJSONExpr — выражение ключа строки в виде JSON-объекта {"pk": value} (формат applyKeyedBatch)
+func pkJSONExpr(pkCols []string) string {
+    parts := make([]string, len(pkCols))
+    for i, c := range pkCols {
+        parts[i] = fmt.Sprintf(`'%s', "%s"`, strings.ReplaceAll(c, "'", "''"), c)
+    }
+    return fmt.Sprintf(`jsonb_build_object(%s)::text`, strings.Join(parts, ", "))
+}
+
+// queryStrings — выполняет запрос с одной текстовой колонкой и возвращает все значения.
+func queryStrings(ctx context.Context, tx *sql.Tx, q string, args ...interface{}) ([]string, error) {
+    rows, err := tx.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []string
+    for rows.Next() {
+        var v string
+        if err := rows.Scan(&v); err != nil {
+            return nil, err
+        }
+        out = append(out, v)
+    }
+    return out, rows.Err()
+}
+
+// loadLastDeleteCheck — время последнего анти-join для таблицы (из pgsyncer.sync_state)
+func loadLastDeleteCheck(ctx context.Context, schema, table string) (sql.NullTime, error) {
+    var last sql.NullTime
+    q := fmt.Sprintf(`SELECT last_delete_check FROM %s.sync_state WHERE schema_name = $1 AND table_name = $2`, stateSchema)
+    err := standinDB.QueryRowContext(ctx, q, schema, table).Scan(&last)
+    if err == sql.ErrNoRows {
+        return sql.NullTime{}, nil
+    }
+    return last, err
+}
+
+// saveLastDeleteCheck — запоминает время успешного анти-join
+func saveLastDeleteCheck(ctx context.Context, schema, table, mode string) error {
+    q := fmt.Sprintf(`
+INSERT INTO %s.sync_state (schema_name, table_name, mode, last_delete_check)
+VALUES ($1, $2, $3, now())
+ON CONFLICT (schema_name, table_name)
+DO UPDATE SET last_delete_check = EXCLUDED.last_delete_check`, stateSchema)
+    _, err := standinDB.ExecContext(ctx, q, schema, table, mode)
+    return err
+}
@@ -0,0 +1,723 @@
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "context"
+    "crypto/sha256"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "hash"
+    "io"
+    "math"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Выгрузка согласованного снимка main в файлы (команда export) — для получателей, у которых
+// не PostgreSQL. Все таблицы читаются в одном снимке: mainTx экспортирует его, воркеры
+// импортируют (как в SyncData), так что выгрузка согласована между таблицами. Таблицы
+// выбираются так же, как при синхронизации (--tables, --partition-mode, --filter,
+// --exclude-columns, --transform), строки читаются курсором пакетами адаптивного
+// размера (--chunk-size, --chunk-target-duration) с учётом ограничений нагрузки.
+//
+// Каталог выгрузки:
+//   <таблица>/part-00000.csv[.gz] | .ndjson[.gz] | .parquet — не больше --export-file-rows строк в файле;
+//   <таблица>/schema.json — столбцы: тип PostgreSQL, тип в файле, PK;
+//   manifest.json — снимок, формат, файлы с числом строк, размером и SHA-256.
+// manifest.json пишется последним: его наличие означает, что выгрузка завершена.
+
+// exportColumn — столбец выгрузки. kind — представление в файле: boolean, int32, int64,
+// double, date, timestamp, timestamptz, bytes, json, string (numeric, uuid, массивы и прочее — текстом).
+type exportColumn struct {
+    name     string
+    pgType   string
+    kind     string
+    nullable bool
+}
+
+// exportFileInfo — файл выгрузки в манифесте
+type exportFileInfo struct {
+    Path   string `json:"path"`
+    Rows   int64  `json:"rows"`
+    Bytes  int64  `json:"bytes"`
+    SHA256 string `json:"sha256"`
+}
+
+// exportTableInfo — таблица в манифесте
+type exportTableInfo struct {
+    Table  string           `json:"table"`
+    Rows   int64            `json:"rows"`
+    Schema string           `json:"schema_file"`
+    Files  []exportFileInfo `json:"files"`
+}
+
+// exportManifest — manifest.json
+type exportManifest struct {
+    Schema       string            `json:"schema"`
+    Format       string            `json:"format"`
+    Compression  string            `json:"compression"`
+    SnapshotXmin string            `json:"snapshot_xmin"`
+    StartedAt    time.Time         `json:"started_at"`
+    FinishedAt   time.Time         `json:"finished_at"`
+    Tables       []exportTableInfo `json:"tables"`
+}
+
+// runExport — команда export: все выбранные таблицы main из одного снимка в --export-dir
+func runExport(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "run_id", newRunID(), "phase", "export", "schema", cfg.Schema)
+    started := time.Now()
+    if err := prepareExportDir(cfg.ExportDir); err != nil {
+        return err
+    }
+
+    mainTx, err := mainDB.BeginTx(snapshotContext(ctx), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+    if err != nil {
+        return fmt.Errorf("BeginTx mainDB: %v", err)
+    }
+    defer mainTx.Rollback()
+    if err := disableStatementTimeout(ctx, mainTx); err != nil {
+        return err
+    }
+
+    manifest := exportManifest{Schema: cfg.Schema, Format: cfg.ExportFormat, Compression: cfg.ExportCompression, StartedAt: started}
+    if err := mainTx.QueryRowContext(snapshotContext(ctx), `SELECT txid_snapshot_xmin(txid_current_snapshot())::text`).Scan(&manifest.SnapshotXmin); err != nil {
+        return fmt.Errorf("txid_current_snapshot: %v", err)
+    }
+    snapshot, err := exportSnapshot(ctx, mainTx)
+    if err != nil {
+        return err
+    }
+
+    tables, err := exportTables(ctx, cfg, mainTx)
+    if err != nil {
+        return err
+    }
+    logFrom(ctx).Info(T("export started"), "dir", cfg.ExportDir, "format", cfg.ExportFormat,
+        "compression", cfg.ExportCompression, "tables", len(tables))
+
+    workerCount := max(cfg.Workers, 1)
+    tableCh := make(chan string, len(tables))
+    for _, t := range tables {
+        tableCh <- t
+    }
+    close(tableCh)
+
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    var firstErr error
+    results := make(map[string]exportTableInfo, len(tables))
+    fail := func(err error) {
+        mu.Lock()
+        if firstErr == nil {
+            firstErr = err
+        }
+        mu.Unlock()
+    }
+    for i := 0; i < workerCount; i++ {
+        wg.Add(1)
+        go func(workerID int) {
+            defer wg.Done()
+            wctx := withLogAttrs(ctx, "worker", workerID)
+            tx, err := beginSnapshotTx(ctx, snapshot)
+            if err != nil {
+                fail(err)
+                return
+            }
+            defer tx.Rollback()
+            for tbl := range tableCh {
+                if err := checkStop(ctx); err != nil {
+                    fail(err)
+                    return
+                }
+                mu.Lock()
+                failed := firstErr != nil
+                mu.Unlock()
+                if failed {
+                    return
+                }
+                tctx := withLogAttrs(wctx, "table", tbl)
+                info, err := exportTable(tctx, cfg, tx, tbl)
+                if err != nil {
+                    logFrom(tctx).Error(T("table export failed"), "error", err)
+                    fail(fmt.Errorf("выгрузка %s: %w", tbl, err))
+                    return
+                }
+                mu.Lock()
+                results[tbl] = info
+                mu.Unlock()
+            }
+        }(i + 1)
+    }
+    wg.Wait()
+    if firstErr != nil {
+        return firstErr
+    }
+
+    var total int64
+    for _, t := range tables {
+        manifest.Tables = append(manifest.Tables, results[t])
+        total += results[t].Rows
+    }
+    manifest.FinishedAt = time.Now()
+    data, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := writeFileAtomic(filepath.Join(cfg.ExportDir, "manifest.json"), append(data, '\n')); err != nil {
+        return fmt.Errorf("запись manifest.json: %v", err)
+    }
+    logFrom(ctx).Info(T("export finished"), "tables", len(tables), "rows", total,
+        "duration", time.Since(started).Round(time.Millisecond))
+    return nil
+}
+
+// prepareExportDir — создаёт каталог выгрузки; непустой каталог не перезаписывается
+func prepareExportDir(dir string) error {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return fmt.Errorf("каталог выгрузки: %v", err)
+    }
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return fmt.Errorf("каталог выгрузки: %v", err)
+    }
+    if len(entries) > 0 {
+        return fmt.Errorf("каталог выгрузки %s не пуст: укажите новый или очистите его", dir)
+    }
+    return nil
+}
+
+// exportTables — таблицы выгрузки: как в SyncData, с учётом --tables и --partition-mode
+func exportTables(ctx context.Context, cfg *Config, mainTx *sql.Tx) ([]string, error) {
+    tables, err := listTables(snapshotContext(ctx), mainTx, cfg.Schema)
+    if err != nil {
+        return nil, fmt.Errorf("listTables(mainDB): %v", err)
+    }
+    if len(cfg.Tables) > 0 {
+        var selected []string
+        for _, t := range tables {
+            if inSlice(cfg.Tables, t) {
+                selected = append(selected, t)
+            }
+        }
+        tables = selected
+    }
+    parts, err := loadPartitions(snapshotContext(ctx), mainTx, cfg.Schema)
+    if err != nil {
+        return nil, fmt.Errorf("loadPartitions(mainDB): %v", err)
+    }
+    return parts.selectLevel(tables, cfg.PartitionMode), nil
+}
+
+// exportColumns — столбцы таблицы main (без --exclude-columns) и их представление в файле
+func exportColumns(ctx context.Context, cfg *Config, tx *sql.Tx, tableName string) ([]exportColumn, error) {
+    rows, err := tx.QueryContext(snapshotContext(ctx), `
+SELECT a.attname, format_type(a.atttypid, a.atttypmod), COALESCE(bt.typname, t.typname), NOT a.attnotnull
+FROM pg_attribute a
+JOIN pg_type t ON t.oid = a.atttypid
+LEFT JOIN pg_type bt ON bt.oid = t.typbasetype AND t.typtype = 'd'
+WHERE a.attrelid = to_regclass(format('%I.%I', $1::text, $2::text))
+  AND a.attnum > 0
+  AND NOT a.attisdropped
+ORDER BY a.attnum`, cfg.Schema, tableName)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var cols []exportColumn
+    for rows.Next() {
+        var c exportColumn
+        var typName string
+        if err := rows.Scan(&c.name, &c.pgType, &typName, &c.nullable); err != nil {
+            return nil, err
+        }
+        if isExcluded(cfg, tableName, c.name) {
+            continue
+        }
+        switch typName {
+        case "bool":
+            c.kind = "boolean"
+        case "int2", "int4":
+            c.kind = "int32"
+        case "int8":
+            c.kind = "int64"
+        case "float4", "float8":
+            c.kind = "double"
+        case "date", "timestamp", "timestamptz":
+            c.kind = typName
+        case "bytea":
+            c.kind = "bytes"
+        case "json", "jsonb":
+            c.kind = "json"
+        default:
+            // numeric — текстом, чтобы не терять точность; uuid, массивы, перечисления и прочее — тоже
+            c.kind = "string"
+        }
+        cols = append(cols, c)
+    }
+    return cols, rows.Err()
+}
+
+// exportTransformType — тип столбца для правил --transform. Для типов, которые выгружаются
+// как есть, — базовый тип (в том числе у доменов): по нему hash и fixed строят значения,
+// которые ждёт запись файла. Остальные читаются текстом и сохраняют свой тип.
+func exportTransformType(c exportColumn) string {
+    switch c.kind {
+    case "int32":
+        return "integer"
+    case "int64":
+        return "bigint"
+    case "double":
+        return "double precision"
+    case "boolean":
+        return "boolean"
+    case "date":
+        return "date"
+    case "timestamp":
+        return "timestamp without time zone"
+    case "timestamptz":
+        return "timestamp with time zone"
+    case "bytes":
+        return "bytea"
+    }
+    return c.pgType
+}
+
+// exportSelect — выражение столбца в SELECT: значения нестандартных типов читаются текстом
+func exportSelect(c exportColumn) string {
+    switch c.kind {
+    case "double":
+        return fmt.Sprintf(`"%s"::float8`, c.name)
+    case "string", "json":
+        return fmt.Sprintf(`"%s"::text`, c.name)
+    }
+    return fmt.Sprintf(`"%s"`, c.name)
+}
+
+// exportTable — выгружает одну таблицу в транзакции воркера tx
+func exportTable(ctx context.Context, cfg *Config, tx *sql.Tx, tableName string) (exportTableInfo, error) {
+    info := exportTableInfo{Table: tableName, Files: []exportFileInfo{}}
+    started := time.Now()
+    logFrom(ctx).Info(T("table export started"))
+
+    cols, err := exportColumns(ctx, cfg, tx, tableName)
+    if err != nil {
+        return info, fmt.Errorf("столбцы: %v", err)
+    }
+    if len(cols) == 0 {
+        return info, fmt.Errorf("у таблицы не осталось столбцов для выгрузки")
+    }
+    names := make([]string, len(cols))
+    types := make([]string, len(cols))
+    selects := make([]string, len(cols))
+    for i, c := range cols {
+        names[i], types[i], selects[i] = c.name, exportTransformType(c), exportSelect(c)
+    }
+    pkCols, _ := detectPK(ctx, tx, cfg.Schema, tableName)
+    tr, err := transformFor(cfg, tableName, names, types, pkCols)
+    if err != nil {
+        return info, err
+    }
+    if tr != nil {
+        // Маскирующие правила меняют тип значения: псевдонимы и хеш не целых — строки.
+        // Константа fixed уже приведена к типу столбца, JSON из неё — просто текст.
+        for i, r := range tr.rules {
+            isInt := cols[i].kind == "int32" || cols[i].kind == "int64"
+            switch {
+            case r.kind == "hash" && isInt:
+                // Хеш целого остаётся в диапазоне типа
+            case r.kind == "fixed" && cols[i].kind != "json":
+            case r.kind == "hash" || r.kind == "fixed" || r.kind == "pseudonym" || r.kind == "email" || r.kind == "phone":
+                cols[i].kind = "string"
+            }
+            if r.kind == "null" {
+                cols[i].nullable = true
+            }
+        }
+    }
+
+    dir := filepath.Join(cfg.ExportDir, tableName)
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return info, err
+    }
+    info.Schema = filepath.ToSlash(filepath.Join(tableName, "schema.json"))
+    if err := writeExportSchema(filepath.Join(cfg.ExportDir, info.Schema), cfg, tableName, cols, pkCols); err != nil {
+        return info, err
+    }
+
+    // Порядок строк — по PK (если он есть): так файлы выгрузки повторяемы
+    order := ""
+    if len(pkCols) > 0 {
+        order = " ORDER BY " + quoteColumns(pkCols)
+    }
+    const cursor = "pgsyncer_export"
+    q := fmt.Sprintf(`DECLARE %s NO SCROLL CURSOR FOR SELECT %s FROM "%s"."%s" WHERE %s%s`,
+        cursor, strings.Join(selects, ", "), cfg.Schema, tableName, filterCond(cfg, tableName), order)
+    if _, err := tx.ExecContext(snapshotContext(ctx), q); err != nil {
+        return info, err
+    }
+    defer tx.ExecContext(snapshotContext(ctx), "CLOSE "+cursor)
+
+    var file *exportFile
+    closeFile := func() error {
+        if file == nil {
+            return nil
+        }
+        fi, err := file.close()
+        file = nil
+        if err != nil {
+            return err
+        }
+        info.Files = append(info.Files, fi)
+        return nil
+    }
+    defer func() {
+        if file != nil {
+            file.abort()
+        }
+    }()
+
+    // Пакеты адаптивного размера: --chunk-size задаёт число строк первого FETCH
+    sizer := newChunkSizer(cfg)
+    for {
+        release, err := throttleFrom(ctx).acquire(ctx)
+        if err == nil {
+            err = checkStop(ctx)
+        }
+        if err != nil {
+            release()
+            return info, err
+        }
+        batchStart := time.Now()
+        batch, err := fetchCursorBatch(ctx, tx, cursor, int(sizer.size), len(cols), tr)
+        release()
+        if err != nil {
+            return info, err
+        }
+        if len(batch) == 0 {
+            break
+        }
+        var batchBytes int64
+        for _, row := range batch {
+            batchBytes += rowBytes(row)
+            if file == nil {
+                part := len(info.Files)
+                if file, err = openExportFile(cfg, tableName, part, cols); err != nil {
+                    return info, err
+                }
+            }
+            if err := file.writeRow(row); err != nil {
+                return info, err
+            }
+            info.Rows++
+            if file.rows >= cfg.ExportFileRows {
+                if err := closeFile(); err != nil {
+                    return info, err
+                }
+            }
+        }
+        metricChunks.Add(tableName, 1)
+        if sizer.observe(time.Since(batchStart), batchBytes) {
+            logChunkSize(ctx, tableName, sizer, time.Since(batchStart), batchBytes)
+        }
+        if err := throttleFrom(ctx).consume(ctx, len(batch), batchBytes); err != nil {
+            return info, err
+        }
+    }
+    if err := closeFile(); err != nil {
+        return info, err
+    }
+    logFrom(ctx).Info(T("table export finished"), "rows", info.Rows, "files", len(info.Files),
+        "duration", time.Since(started).Round(time.Millisecond))
+    return info, nil
+}
+
+// writeExportSchema — schema.json таблицы: столбцы с типами PostgreSQL и файла, PK
+func writeExportSchema(path string, cfg *Config, tableName string, cols []exportColumn, pkCols []string) error {
+    type schemaColumn struct {
+        Name     string `json:"name"`
+        PgType   string `json:"pg_type"`
+        Type     string `json:"type"`
+        Nullable bool   `json:"nullable"`
+        Infinity string `json:"infinity,omitempty"` // как записаны ±infinity (только Parquet)
+    }
+    doc := struct {
+        Schema     string         `json:"schema"`
+        Table      string         `json:"table"`
+        PrimaryKey []string       `json:"primary_key"`
+        Columns    []schemaColumn `json:"columns"`
+    }{Schema: cfg.Schema, Table: tableName, PrimaryKey: pkCols}
+    if doc.PrimaryKey == nil {
+        doc.PrimaryKey = []string{}
+    }
+    for _, c := range cols {
+        sc := schemaColumn{Name: c.name, PgType: c.pgType, Type: c.kind, Nullable: c.nullable}
+        if cfg.ExportFormat == "parquet" {
+            switch c.kind {
+            case "date":
+                sc.Infinity = "int32_min_max"
+            case "timestamp", "timestamptz":
+                sc.Infinity = "int64_min_max"
+            }
+        }
+        doc.Columns = append(doc.Columns, sc)
+    }
+    data, err := json.MarshalIndent(doc, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// rowEncoder — запись строк в формате выгрузки
+type rowEncoder interface {
+    writeRow(row []interface{}) error
+    close() error
+}
+
+// countingWriter — считает записанные байты и SHA-256 файла
+type countingWriter struct {
+    w    io.Writer
+    hash hash.Hash
+    n    int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.hash.Write(p[:n])
+    c.n += int64(n)
+    return n, err
+}
+
+// exportFile — открытый файл выгрузки: файл <- буфер <- счётчик/SHA-256 <- [gzip] <- формат
+type exportFile struct {
+    path  string
+    rel   string
+    f     *os.File
+    buf   *bufio.Writer
+    count *countingWriter
+    gz    *gzip.Writer
+    enc   rowEncoder
+    rows  int64
+}
+
+// openExportFile — part-NNNNN.<формат>[.gz] в каталоге таблицы
+func openExportFile(cfg *Config, tableName string, part int, cols []exportColumn) (*exportFile, error) {
+    name := fmt.Sprintf("part-%05d.%s", part, cfg.ExportFormat)
+    if cfg.ExportCompression == "gzip" && cfg.ExportFormat != "parquet" {
+        name += ".gz"
+    }
+    ef := &exportFile{rel: filepath.ToSlash(filepath.Join(tableName, name))}
+    ef.path = filepath.Join(cfg.ExportDir, tableName, name)
+    f, err := os.Create(ef.path)
+    if err != nil {
+        return nil, err
+    }
+    ef.f = f
+    ef.buf = bufio.NewWriterSize(f, 1<<20)
+    ef.count = &countingWriter{w: ef.buf, hash: sha256.New()}
+
+    var out io.Writer = ef.count
+    if cfg.ExportCompression == "gzip" && cfg.ExportFormat != "parquet" {
+        // Parquet сжимает страницы сам: файл остаётся читаемым без распаковки
+        ef.gz = gzip.NewWriter(ef.count)
+        out = ef.gz
+    }
+    switch cfg.ExportFormat {
+    case "csv":
+        ef.enc, err = newCSVEncoder(out, cols)
+    case "ndjson":
+        ef.enc = &ndjsonEncoder{w: bufio.NewWriter(out), cols: cols}
+    case "parquet":
+        ef.enc, err = newParquetWriter(out, cols, cfg.ExportCompression)
+    }
+    if err != nil {
+        ef.abort()
+        return nil, err
+    }
+    return ef, nil
+}
+
+func (ef *exportFile) writeRow(row []interface{}) error {
+    ef.rows++
+    return ef.enc.writeRow(row)
+}
+
+// close — дописывает файл и возвращает его запись для манифеста
+func (ef *exportFile) close() (exportFileInfo, error) {
+    err := ef.enc.close()
+    if ef.gz != nil && err == nil {
+        err = ef.gz.Close()
+    }
+    if err == nil {
+        err = ef.buf.Flush()
+    }
+    if err == nil {
+        err = ef.f.Sync()
+    }
+    if cerr := ef.f.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        return exportFileInfo{}, fmt.Errorf("%s: %v", ef.path, err)
+    }
+    return exportFileInfo{Path: ef.rel, Rows: ef.rows, Bytes: ef.count.n, SHA256: hex.EncodeToString(ef.count.hash.Sum(nil))}, nil
+}
+
+// abort — закрывает недописанный файл после ошибки (выгрузка без манифеста не считается завершённой)
+func (ef *exportFile) abort() {
+    ef.f.Close()
+}
+
+// csvEncoder — CSV с заголовком. NULL — пустое поле, пустая строка — "" (как COPY ... CSV).
+type csvEncoder struct {
+    w    *bufio.Writer
+    cols []exportColumn
+}
+
+func newCSVEncoder(w io.Writer, cols []exportColumn) (*csvEncoder, error) {
+    e := &csvEncoder{w: bufio.NewWriter(w), cols: cols}
+    for i, c := range cols {
+        if i > 0 {
+            e.w.WriteByte(',')
+        }
+        e.writeField(c.name)
+    }
+    return e, e.w.WriteByte('\n')
+}
+
+func (e *csvEncoder) writeField(s string) {
+    if s != "" && !strings.ContainsAny(s, ",\"\r\n") && strings.TrimSpace(s) == s {
+        e.w.WriteString(s)
+        return
+    }
+    e.w.WriteByte('"')
+    e.w.WriteString(strings.ReplaceAll(s, `"`, `""`))
+    e.w.WriteByte('"')
+}
+
+func (e *csvEncoder) writeRow(row []interface{}) error {
+    for i, v := range row {
+        if i > 0 {
+            e.w.WriteByte(',')
+        }
+        if v != nil {
+            e.writeField(exportValueText(e.cols[i], v))
+        }
+    }
+    return e.w.WriteByte('\n')
+}
+
+func (e *csvEncoder) close() error {
+    return e.w.Flush()
+}
+
+// ndjsonEncoder — строка на объект {"столбец": значение}; json/jsonb вкладываются как есть
+type ndjsonEncoder struct {
+    w    *bufio.Writer
+    cols []exportColumn
+}
+
+func (e *ndjsonEncoder) writeRow(row []interface{}) error {
+    e.w.WriteByte('{')
+    for i, v := range row {
+        if i > 0 {
+            e.w.WriteByte(',')
+        }
+        key, _ := json.Marshal(e.cols[i].name)
+        e.w.Write(key)
+        e.w.WriteByte(':')
+        e.w.Write(ndjsonValue(e.cols[i], v))
+    }
+    e.w.WriteByte('}')
+    return e.w.WriteByte('\n')
+}
+
+func (e *ndjsonEncoder) close() error {
+    return e.w.Flush()
+}
+
+// ndjsonValue — значение в JSON: числа и логические — как есть, NaN/Infinity и прочее — строками
+func ndjsonValue(c exportColumn, v interface{}) []byte {
+    switch x := v.(type) {
+    case nil:
+        return []byte("null")
+    case bool:
+        return strconv.AppendBool(nil, x)
+    case int64:
+        return strconv.AppendInt(nil, x, 10)
+    case float64:
+        if !math.IsNaN(x) && !math.IsInf(x, 0) {
+            return strconv.AppendFloat(nil, x, 'g', -1, 64)
+        }
+    case string:
+        if c.kind == "json" && json.Valid([]byte(x)) {
+            return []byte(x)
+        }
+    }
+    b, _ := json.Marshal(exportValueText(c, v))
+    return b
+}
+
+// exportValueText — текстовое представление значения для CSV и NDJSON
+func exportValueText(c exportColumn, v interface{}) string {
+    if t, ok := v.(time.Time); ok {
+        switch c.kind {
+        case "date":
+            return t.Format("2006-01-02")
+        case "timestamp":
+            return exportLocalTime(t).Format("2006-01-02T15:04:05.999999")
+        }
+        return t.UTC().Format(time.RFC3339Nano)
+    }
+    if b, ok := v.([]byte); ok && c.kind == "bytes" {
+        return `\x` + hex.EncodeToString(b)
+    }
+    return exportText(v)
+}
+
+// exportText — значение как текст (строки, числа, логические)
+func exportText(v interface{}) string {
+    switch x := v.(type) {
+    case string:
+        return x
+    case []byte:
+        return string(x)
+    case float64:
+        return strconv.FormatFloat(x, 'g', -1, 64)
+    case time.Time:
+        return x.UTC().Format(time.RFC3339Nano)
+    }
+    return fmt.Sprintf("%v", v)
+}
+
+// exportInt — целое значение (pgx отдаёт все целые как int64, правила hash и fixed — тоже)
+func exportInt(v interface{}) (int64, error) {
+    if x, ok := v.(int64); ok {
+        return x, nil
+    }
+    return 0, fmt.Errorf("ожидалось целое, получено %T", v)
+}
+
+// exportDays — дата как число дней от 1970-01-01
+func exportDays(t time.Time) int64 {
+    y, m, d := t.Date()
+    secs := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()
+    days := secs / 86400
+    if secs%86400 != 0 && secs < 0 {
+        days--
+    }
+    return days
+}
+
+// exportLocalTime — timestamp без зоны: настенное время как есть, в UTC
+func exportLocalTime(t time.Time) time.Time {
+    return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
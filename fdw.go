@@ -2,21 +2,32 @@ package main
 
 import (
     "context"
+    "database/sql"
     "fmt"
-    "log"
     "net/url"
     "strings"
 )
 
-// setupFDW — создаёт расширение postgres_fdw в резервной БД, настраивает SERVER и USER MAPPING.
-func setupFDW(cfg *Config) error {
-    ctx := context.Background()
+// fdwSchema — схема standin для внешних таблиц main
+const fdwSchema = "pgsyncer_fdw"
+
+// fdwNames — схема внешних таблиц и имя сервера. При --standin-schema у каждой
+// копии свои: рядом в одной standin могут жить копии нескольких main.
+func fdwNames(cfg *Config) (schema, server string) {
+    if cfg.StandinSchema == cfg.Schema {
+        return fdwSchema, "main_server"
+    }
+    return fdwSchema + "_" + cfg.StandinSchema, "main_server_" + cfg.StandinSchema
+}
 
+// setupFDW — создаёт расширение postgres_fdw в резервной БД, настраивает SERVER и USER MAPPING.
+func setupFDW(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "fdw")
     // 1) Создаём EXTENSION postgres_fdw (если не существует)
     if _, err := standinDB.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS postgres_fdw;`); err != nil {
         return fmt.Errorf("CREATE EXTENSION postgres_fdw: %v", err)
     }
-    log.Println("[FDW] Расширение postgres_fdw создано (если не было)")
+    logFrom(ctx).Info(T("postgres_fdw extension created (if missing)"))
 
     // 2) Разбираем cfg.MainDSN, чтобы получить user, pass, host, port, dbname, sslmode и т.д.
     dsnParts, err := parsePostgresDSN(cfg.MainDSN)
@@ -24,8 +35,7 @@ func setupFDW(cfg *Config) error {
         return fmt.Errorf("parse DSN err: %v", err)
     }
 
-    // Можно сделать имя сервера настраиваемым, а можно захардкодить. Например:
-    serverName := "main_server"
+    foreignSchema, serverName := fdwNames(cfg)
 
     // 3) CREATE SERVER ... IF NOT EXISTS
     var extraOpts string
@@ -34,14 +44,14 @@ func setupFDW(cfg *Config) error {
     }
 
     createServer := fmt.Sprintf(`
-CREATE SERVER IF NOT EXISTS %s
+CREATE SERVER IF NOT EXISTS "%s"
 FOREIGN DATA WRAPPER postgres_fdw
 OPTIONS (host '%s', dbname '%s', port '%s' %s);
 `,
         serverName, dsnParts.host, dsnParts.dbname, dsnParts.port, extraOpts)
 
-    log.Printf("[FDW] Создаём сервер %q c хостом=%s dbname=%s port=%s sslmode=%s",
-        serverName, dsnParts.host, dsnParts.dbname, dsnParts.port, dsnParts.sslmode)
+    logFrom(ctx).Info(T("creating foreign server"), "server", serverName, "host", dsnParts.host,
+        "dbname", dsnParts.dbname, "port", dsnParts.port, "sslmode", dsnParts.sslmode)
 
     if _, err := standinDB.ExecContext(ctx, createServer); err != nil {
         return fmt.Errorf("CREATE SERVER: %v", err)
@@ -50,32 +60,123 @@ OPTIONS (host '%s', dbname '%s', port '%s' %s);
     // 4) CREATE USER MAPPING IF NOT EXISTS
     createUserMap := fmt.Sprintf(`
 CREATE USER MAPPING IF NOT EXISTS FOR CURRENT_USER
-SERVER %s
+SERVER "%s"
 OPTIONS (user '%s', password '%s');
 `, serverName, dsnParts.user, dsnParts.password)
 
-    log.Printf("[FDW] Создаём USER MAPPING для пользователя %q", dsnParts.user)
+    logFrom(ctx).Info(T("creating user mapping"), "user", dsnParts.user)
 
     if _, err := standinDB.ExecContext(ctx, createUserMap); err != nil {
         return fmt.Errorf("CREATE USER MAPPING: %v", err)
     }
 
     // 5) IMPORT FOREIGN SCHEMA
-    // По умолчанию копируем все таблицы из cfg.Schema в эту же схему.
-    importSQL := fmt.Sprintf(`
+    // Внешние таблицы импортируются в отдельную схему pgsyncer_fdw под именами main:
+    // в схеме standin лежат настоящие таблицы. Схема пересоздаётся, чтобы подхватить DDL main.
+    importSQL := []string{
+        fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, foreignSchema),
+        fmt.Sprintf(`CREATE SCHEMA "%s"`, foreignSchema),
+        fmt.Sprintf(`
 IMPORT FOREIGN SCHEMA "%s"
-FROM SERVER %s
+FROM SERVER "%s"
 INTO "%s";
-`, cfg.Schema, serverName, cfg.Schema)
+`, cfg.Schema, serverName, foreignSchema),
+    }
+
+    logFrom(ctx).Info(T("importing foreign schema"), "schema", cfg.Schema, "into", foreignSchema)
+
+    for _, q := range importSQL {
+        if _, err := standinDB.ExecContext(ctx, q); err != nil {
+            logFrom(ctx).Warn(T("foreign schema import failed"), "schema", cfg.Schema, "error", err)
+            return fmt.Errorf("IMPORT FOREIGN SCHEMA: %v", err)
+        }
+    }
+
+    logFrom(ctx).Info(T("postgres_fdw configured"))
+    return nil
+}
+
+// syncTableFDW — синхронизация таблицы целиком на стороне standin через внешнюю таблицу
+// pgsyncer_fdw.<таблица main>: upsert изменившихся строк и удаление отсутствующих в main.
+// Строки читает сам standin, поэтому снимок mainTx здесь не используется, а --filter
+// применяется к обеим сторонам одинаково.
+func syncTableFDW(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    schema := cfg.Schema
+    pkCols, _ := detectPK(ctx, mainTx, schema, tableName)
+    if len(pkCols) == 0 {
+        logFrom(ctx).Warn(T("table has no PK, rows cannot be matched, skipping"))
+        return nil
+    }
+    columns, _, err := syncColumns(ctx, cfg, mainTx, tableName)
+    if err != nil {
+        return fmt.Errorf("[syncTableFDW] syncColumns(%s): %v", tableName, err)
+    }
+    filter := filterCond(cfg, tableName)
+    foreignSchema, _ := fdwNames(cfg)
+    sSchema, sTable := cfg.StandinSchema, standinTable(cfg, tableName)
+
+    colList := quoteColumns(columns)
+    pkList := quoteColumns(pkCols)
+    identity := alwaysIdentity(sSchema, sTable)
+    var sets, cur, excl []string
+    for _, c := range columns {
+        cur = append(cur, fmt.Sprintf(`t."%s"`, c))
+        excl = append(excl, fmt.Sprintf(`EXCLUDED."%s"`, c))
+        if !inSlice(pkCols, c) && !inSlice(identity, c) {
+            sets = append(sets, fmt.Sprintf(`"%[1]s" = EXCLUDED."%[1]s"`, c))
+        }
+    }
+    conflict := "DO NOTHING"
+    if len(sets) > 0 {
+        conflict = fmt.Sprintf("DO UPDATE SET %s WHERE ROW(%s) IS DISTINCT FROM ROW(%s)",
+            strings.Join(sets, ", "), strings.Join(cur, ", "), strings.Join(excl, ", "))
+    }
+    upsertQ := fmt.Sprintf(`
+INSERT INTO "%[1]s"."%[2]s" AS t (%[3]s) %[10]s
+SELECT %[9]s FROM "%[4]s"."%[8]s" WHERE %[5]s
+ON CONFLICT (%[6]s) %[7]s`, sSchema, sTable, colList, foreignSchema, filter, pkList, conflict, tableName,
+        selectColumns("", columns, castsFor(cfg, tableName)), overridingClause(sSchema, sTable, columns))
+
+    // Внутри NOT EXISTS неквалифицированные столбцы фильтра относятся к внешней таблице f
+    matchConds := make([]string, len(pkCols))
+    for i, c := range pkCols {
+        matchConds[i] = fmt.Sprintf(`f."%[1]s" = t."%[1]s"`, c)
+    }
+    deleteQ := fmt.Sprintf(`
+WITH d AS (
+    DELETE FROM "%[1]s"."%[2]s" t
+    WHERE %[3]s
+      AND NOT EXISTS (SELECT 1 FROM "%[4]s"."%[6]s" f WHERE %[5]s AND %[3]s)
+    RETURNING 1
+)
+SELECT count(*) FROM d`, sSchema, sTable, filter, foreignSchema, strings.Join(matchConds, " AND "), tableName)
 
-    log.Printf("[FDW] IMPORT FOREIGN SCHEMA %q INTO %q", cfg.Schema, cfg.Schema)
+    // Оба запроса читают main через postgres_fdw: каждый занимает место --max-main-queries.
+    // Предел скорости к ним не применяется — строки не проходят через pgsyncer.
+    release, err := throttleFrom(ctx).acquire(ctx)
+    if err != nil {
+        return err
+    }
+    res, err := standinDB.ExecContext(applyContext(ctx), upsertQ)
+    release()
+    if err != nil {
+        return fmt.Errorf("[syncTableFDW] upsert %s: %v", tableName, err)
+    }
+    upserted, _ := res.RowsAffected()
+    metricRowsUpdated.Add(tableName, float64(upserted))
 
-    if _, err := standinDB.ExecContext(ctx, importSQL); err != nil {
-        // Не всегда критично, поэтому делаем Warning
-        log.Printf("[WARN] Не удалось импортировать схему %s: %v", cfg.Schema, err)
+    if release, err = throttleFrom(ctx).acquire(ctx); err != nil {
+        return err
+    }
+    deleted, err := execCountedDelete(ctx, deleteQ)
+    release()
+    if err != nil {
+        return fmt.Errorf("[syncTableFDW] delete %s: %w", tableName, err)
     }
+    metricRowsDeleted.Add(tableName, float64(deleted))
+    metricChunks.Add(tableName, 1)
 
-    log.Println("[FDW] postgres_fdw настроен, схема импортирована (если удалось).")
+    logFrom(ctx).Info(T("table synced via FDW"), "upserted", upserted, "deleted", deleted)
     return nil
 }
 
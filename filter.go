@@ -0,0 +1,135 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+)
+
+// Фильтры строк (--filter "таблица=условие"): синхронизируется только подмножество строк,
+// например tenant_id = 42 или created_at > now() - interval '90 days'.
+// Условие одинаково добавляется к чтению main и standin во всех режимах, поэтому строки
+// вне фильтра никогда не считаются удалёнными. Строки standin вне фильтра остаются
+// как есть, пока не задан --filter-purge.
+
+// tableFilters — фильтры --filter: таблица -> условие WHERE
+type tableFilters map[string]string
+
+// parseFilters — разбирает значения --filter в map[таблица]условие
+func parseFilters(specs []string) (tableFilters, error) {
+    out := make(tableFilters)
+    for _, spec := range specs {
+        eq := strings.Index(spec, "=")
+        if eq <= 0 || strings.TrimSpace(spec[eq+1:]) == "" {
+            return nil, fmt.Errorf("неверный фильтр %q (ожидается таблица=условие)", spec)
+        }
+        table := strings.TrimSpace(spec[:eq])
+        if _, dup := out[table]; dup {
+            return nil, fmt.Errorf("фильтр для таблицы %s задан дважды", table)
+        }
+        out[table] = strings.TrimSpace(spec[eq+1:])
+    }
+    return out, nil
+}
+
+// filterCond — условие фильтра таблицы для WHERE; "TRUE", если фильтра нет.
+// NULL в условии считается «вне фильтра», как и в обычном WHERE.
+func filterCond(cfg *Config, tableName string) string {
+    if f, ok := cfg.Filters[tableName]; ok {
+        return "(" + f + ")"
+    }
+    return "TRUE"
+}
+
+// filterCondAlias — условие фильтра для таблицы под алиасом alias (в запросах с JOIN).
+// Столбцы в условии не квалифицированы, поэтому оно вычисляется на строке алиаса
+// через подзапрос: (SELECT условие FROM (SELECT alias.*) s).
+func filterCondAlias(cfg *Config, tableName, alias string) string {
+    f, ok := cfg.Filters[tableName]
+    if !ok {
+        return "TRUE"
+    }
+    return fmt.Sprintf("(SELECT (%s) FROM (SELECT %s.*) s)", f, alias)
+}
+
+// purgeOutsideFilter — --filter-purge: удаляет из standin строки, не проходящие фильтр.
+// Удаление идёт в транзакции и откатывается, если нарушен --max-delete-ratio.
+func purgeOutsideFilter(ctx context.Context, cfg *Config, tableName string) error {
+    f, ok := cfg.Filters[tableName]
+    if !ok || !cfg.FilterPurge {
+        return nil
+    }
+    q := fmt.Sprintf(`
+WITH d AS (DELETE FROM "%s"."%s" WHERE NOT COALESCE((%s), false) RETURNING 1)
+SELECT count(*) FROM d`, cfg.StandinSchema, standinTable(cfg, tableName), f)
+    n, err := execCountedDelete(ctx, q)
+    if err != nil {
+        return fmt.Errorf("очистка строк вне фильтра %s: %w", tableName, err)
+    }
+    if n > 0 {
+        metricRowsDeleted.Add(tableName, float64(n))
+        logFrom(ctx).Info(T("rows outside filter purged"), "deleted", n, "filter", f)
+    }
+    return nil
+}
+
+// execCountedDelete — выполняет запрос вида WITH d AS (DELETE ... RETURNING 1) SELECT count(*) FROM d
+// в транзакции standin и фиксирует её, только если удаление укладывается в защитные пороги.
+func execCountedDelete(ctx context.Context, q string, args ...interface{}) (int, error) {
+    ctx = applyContext(ctx)
+    tx, err := standinDB.BeginTx(ctx, nil)
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    var n int
+    if err := tx.QueryRowContext(ctx, q, args...).Scan(&n); err != nil {
+        return 0, err
+    }
+    if err := checkDeleteAllowed(ctx, n); err != nil {
+        return 0, err
+    }
+    return n, tx.Commit()
+}
+
+// fetchRowsByKey — читает строки таблицы (с учётом фильтра) целиком: ключ строки в формате
+// applyKeyedBatch -> хеш значений и ключ -> значения. tr (для main) применяется до хеширования.
+func fetchRowsByKey(ctx context.Context, db interface {
+    QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+},
+    schema, table string,
+    columns []string,
+    casts map[string]string,
+    pkCols []string,
+    filter string,
+    tr *tableTransform,
+) (map[string]string, map[string][]interface{}, error) {
+    q := fmt.Sprintf(`SELECT %s, %s FROM "%s"."%s" WHERE %s`,
+        pkJSONExpr(pkCols), selectColumns("", columns, casts), schema, table, filter)
+    rows, err := db.QueryContext(ctx, q)
+    if err != nil {
+        return nil, nil, err
+    }
+    defer rows.Close()
+
+    dataHash := make(map[string]string)
+    dataRows := make(map[string][]interface{})
+    for rows.Next() {
+        var key string
+        vals := make([]interface{}, len(columns))
+        ptrs := make([]interface{}, len(vals)+1)
+        ptrs[0] = &key
+        for i := range vals {
+            ptrs[i+1] = &vals[i]
+        }
+        if err := rows.Scan(ptrs...); err != nil {
+            return nil, nil, err
+        }
+        tr.apply(vals)
+        dataHash[key] = rowHash(vals)
+        dataRows[key] = vals
+    }
+    return dataHash, dataRows, rows.Err()
+}
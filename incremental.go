@@ -0,0 +1,322 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Состояние инкрементальной синхронизации хранится в резервной БД в схеме pgsyncer:
+// для каждой таблицы — xmin-горизонт и время начала снимка предыдущего успешного запуска.
+const stateSchema = "pgsyncer"
+
+// syncState — запись pgsyncer.sync_state для одной таблицы
+type syncState struct {
+    lastXmin     sql.NullInt64 // xmin-горизонт снимка (64-битный xid8) прошлого запуска
+    lastCommitTs sql.NullTime  // начало снимка прошлого запуска (для commit-ts режима)
+}
+
+// snapshotInfo — параметры снимка mainTx (в REPEATABLE READ одинаковы для всех таблиц запуска)
+type snapshotInfo struct {
+    xmin    int64     // pg_snapshot_xmin: все xid ниже — завершены и видны/откатаны
+    xmax    int64     // pg_snapshot_xmax: первый ещё не выданный xid на момент снимка
+    startTs time.Time // now() транзакции — не позже момента взятия снимка
+}
+
+// ensureSyncState — создаёт в standinDB таблицу pgsyncer.sync_state (если её нет).
+func ensureSyncState(ctx context.Context) error {
+    ddl := fmt.Sprintf(`
+CREATE SCHEMA IF NOT EXISTS %[1]s;
+CREATE TABLE IF NOT EXISTS %[1]s.sync_state (
+    schema_name    text        NOT NULL,
+    table_name     text        NOT NULL,
+    mode           text        NOT NULL,
+    last_xmin      bigint,
+    last_commit_ts timestamptz,
+    last_sync_at   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (schema_name, table_name)
+);
+ALTER TABLE %[1]s.sync_state ADD COLUMN IF NOT EXISTS last_delete_check timestamptz;
+ALTER TABLE %[1]s.sync_state ADD COLUMN IF NOT EXISTS resume_from bigint;
+ALTER TABLE %[1]s.sync_state ADD COLUMN IF NOT EXISTS interrupted_at timestamptz;
+`, stateSchema)
+    if _, err := standinDB.ExecContext(ctx, ddl); err != nil {
+        return fmt.Errorf("создание %s.sync_state: %v", stateSchema, err)
+    }
+    return nil
+}
+
+// loadSyncState — читает сохранённое состояние таблицы; пустой syncState, если запуск первый
+// или предыдущий запуск был в другом режиме.
+func loadSyncState(ctx context.Context, schema, table, mode string) (syncState, error) {
+    var st syncState
+    q := fmt.Sprintf(`
+SELECT last_xmin, last_commit_ts
+FROM %s.sync_state
+WHERE schema_name = $1 AND table_name = $2 AND mode = $3`, stateSchema)
+    err := standinDB.QueryRowContext(ctx, q, schema, table, mode).Scan(&st.lastXmin, &st.lastCommitTs)
+    if err == sql.ErrNoRows {
+        return syncState{}, nil
+    }
+    return st, err
+}
+
+// saveSyncState — запоминает горизонт текущего снимка после успешной синхронизации таблицы.
+func saveSyncState(ctx context.Context, schema, table, mode string, snap *snapshotInfo) error {
+    q := fmt.Sprintf(`
+INSERT INTO %s.sync_state (schema_name, table_name, mode, last_xmin, last_commit_ts, last_sync_at)
+VALUES ($1, $2, $3, $4, $5, now())
+ON CONFLICT (schema_name, table_name)
+DO UPDATE SET mode = EXCLUDED.mode,
+              last_xmin = EXCLUDED.last_xmin,
+              last_commit_ts = EXCLUDED.last_commit_ts,
+              last_sync_at = EXCLUDED.last_sync_at`, stateSchema)
+    _, err := standinDB.ExecContext(ctx, q, schema, table, mode, snap.xmin, snap.startTs)
+    return err
+}
+
+type snapshotInfoKey struct{}
+
+// withSnapshotInfo — кладёт параметры снимка mainTx в контекст прохода
+func withSnapshotInfo(ctx context.Context, snap *snapshotInfo) context.Context {
+    return context.WithValue(ctx, snapshotInfoKey{}, snap)
+}
+
+// snapshotInfoFrom — параметры снимка из контекста; nil, если SyncData их не считывал
+func snapshotInfoFrom(ctx context.Context) *snapshotInfo {
+    snap, _ := ctx.Value(snapshotInfoKey{}).(*snapshotInfo)
+    return snap
+}
+
+// readSnapshotInfo — считывает xmin/xmax снимка mainTx и время начала транзакции.
+// Вызывается только на самой mainTx: транзакции воркеров начинаются позже экспорта
+// снимка, и их now() был бы позже снимка — строки, зафиксированные в этом промежутке,
+// оказались бы ниже сохранённого commit-ts горизонта и не перенеслись бы никогда.
+func readSnapshotInfo(ctx context.Context, mainTx *sql.Tx) (*snapshotInfo, error) {
+    snap := &snapshotInfo{}
+    q := `
+SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint,
+       pg_snapshot_xmax(pg_current_snapshot())::text::bigint,
+       now()`
+    if err := mainTx.QueryRowContext(snapshotContext(ctx), q).Scan(&snap.xmin, &snap.xmax, &snap.startTs); err != nil {
+        return nil, fmt.Errorf("чтение снимка mainTx: %v", err)
+    }
+    return snap, nil
+}
+
+// checkCommitTimestamps — commit-ts режим требует track_commit_timestamp = on на main.
+func checkCommitTimestamps(ctx context.Context, mainTx *sql.Tx) error {
+    var val string
+    if err := mainTx.QueryRowContext(snapshotContext(ctx), `SHOW track_commit_timestamp`).Scan(&val); err != nil {
+        return fmt.Errorf("SHOW track_commit_timestamp: %v", err)
+    }
+    if val != "on" {
+        return fmt.Errorf("incremental=commit-ts требует track_commit_timestamp = on (сейчас %q)", val)
+    }
+    return nil
+}
+
+// xid8Expr — SQL-выражение, переводящее 32-битный xmin строки в 64-битный xid8
+// относительно xmax снимка ($1). Видимая строка всегда старше xmax, поэтому если
+// младшие 32 бита xmin не меньше младших бит xmax — строка из предыдущей эпохи.
+// Так сравнение с сохранённым горизонтом переживает wraparound счётчика.
+const xid8Expr = `(($1::bigint >> 32 << 32) + xmin::text::bigint
+    - CASE WHEN xmin::text::bigint >= ($1::bigint & 4294967295) THEN 4294967296 ELSE 0 END)`
+
+// syncTableByXmin — инкрементальная синхронизация по xmin (режим xmin) или по
+// pg_xact_commit_timestamp(xmin) (режим commit-ts). Первый запуск — полная синхронизация,
+// после него сохраняется горизонт снимка, и дальше переносятся только строки,
+// изменённые транзакциями начиная с этого горизонта.
+func syncTableByXmin(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    schema := cfg.Schema
+    mode := cfg.IncrementalMode
+
+    // Горизонт берётся из mainTx (см. readSnapshotInfo), а не из транзакции воркера
+    snap := snapshotInfoFrom(ctx)
+    if snap == nil {
+        return fmt.Errorf("[syncTableByXmin] %s: параметры снимка mainTx не считаны", tableName)
+    }
+
+    pkCols, numericPK := detectPK(ctx, mainTx, schema, tableName)
+    if len(pkCols) == 0 {
+        logFrom(ctx).Warn(T("table has no PK, incremental mode impossible, using full diff"))
+        return syncTableFullDiff(ctx, cfg, mainTx, tableName, nil)
+    }
+
+    st, err := loadSyncState(ctx, cfg.StandinSchema, standinTable(cfg, tableName), mode)
+    if err != nil {
+        return fmt.Errorf("loadSyncState(%s): %v", tableName, err)
+    }
+
+    var where string
+    var args []interface{}
+    switch {
+    case mode == "xmin" && st.lastXmin.Valid:
+        // Горизонт = xmin прошлого снимка: транзакции, шедшие в момент снимка,
+        // тоже попадут сюда — лишний повтор безопасен, пропуск — нет.
+        where = xid8Expr + ` >= $2`
+        args = []interface{}{snap.xmax, st.lastXmin.Int64}
+    case mode == "commit-ts" && st.lastCommitTs.Valid:
+        where = `pg_xact_commit_timestamp(xmin) >= $1`
+        args = []interface{}{st.lastCommitTs.Time}
+    default:
+        logFrom(ctx).Info(T("no saved horizon, running full sync"), "mode", mode)
+        complete, err := syncTableByKeys(ctx, cfg, mainTx, tableName, pkCols, numericPK)
+        if err != nil {
+            return err
+        }
+        if !complete {
+            // Без горизонта следующий запуск снова выполнит полную синхронизацию
+            logFrom(ctx).Warn(T("initial sync incomplete, horizon not saved"), "mode", mode)
+            return nil
+        }
+        return saveSyncState(ctx, cfg.StandinSchema, standinTable(cfg, tableName), mode, snap)
+    }
+
+    n, deleted, err := syncRowsWhere(ctx, cfg, mainTx, tableName, pkCols, where, args...)
+    if err != nil {
+        return err
+    }
+    logFrom(ctx).Info(T("changed rows transferred"), "mode", mode, "rows", n)
+    if cfg.DeleteDetection == "soft" {
+        reportDeletes(ctx, "soft", deleted)
+    }
+
+    return saveSyncState(ctx, cfg.StandinSchema, standinTable(cfg, tableName), mode, snap)
+}
+
+// syncRowsWhere — читает из mainTx строки таблицы, подходящие под условие where,
+// и пачками делает upsert в standinDB. Если включён soft-delete, помеченные строки
+// не копируются, а удаляются из standinDB. Возвращает число перенесённых и удалённых строк.
+func syncRowsWhere(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string, pkCols []string, where string, args ...interface{}) (int, int, error) {
+    schema := cfg.Schema
+
+    columns, types, err := syncColumns(ctx, cfg, mainTx, tableName)
+    if err != nil {
+        return 0, 0, fmt.Errorf("syncColumns(%s): %v", tableName, err)
+    }
+    if len(columns) == 0 {
+        return 0, 0, nil
+    }
+
+    tr, err := transformFor(cfg, tableName, columns, types, pkCols)
+    if err != nil {
+        return 0, 0, err
+    }
+
+    // Признак мягкого удаления и ключ строки (для DELETE) вычисляем прямо в SELECT
+    deletedExpr := "false"
+    if cfg.DeleteDetection == "soft" {
+        deletedExpr, err = softDeleteExpr(ctx, mainTx, schema, tableName, cfg.SoftDeleteColumn)
+        if err != nil {
+            return 0, 0, err
+        }
+    }
+
+    q := fmt.Sprintf(`SELECT %s, %s, %s FROM "%s"."%s" WHERE (%s) AND %s`,
+        deletedExpr, pkJSONExpr(pkCols), selectColumns("", columns, castsFor(cfg, tableName)), schema, tableName, where, filterCond(cfg, tableName))
+    // Выборка читается потоком: место запроса к main (--max-main-queries) занято до её конца
+    release, err := throttleFrom(ctx).acquire(ctx)
+    if err != nil {
+        return 0, 0, err
+    }
+    defer release()
+    rows, err := mainTx.QueryContext(snapshotContext(ctx), q, args...)
+    if err != nil {
+        return 0, 0, fmt.Errorf("выборка изменённых строк %s: %v", tableName, err)
+    }
+    defer rows.Close()
+
+    batchSize := upsertBatchSize(len(columns), cfg.ChunkSize)
+    var batch [][]interface{}
+    var deleteKeys []string
+    var batchBytes int64
+    upserted, deleted := 0, 0
+
+    flush := func() error {
+        if len(batch) == 0 && len(deleteKeys) == 0 {
+            return nil
+        }
+        if err := applyKeyedBatch(ctx, cfg.StandinSchema, standinTable(cfg, tableName), columns, pkCols, batch, deleteKeys); err != nil {
+            return fmt.Errorf("upsert %s: %v", tableName, err)
+        }
+        upserted += len(batch)
+        deleted += len(deleteKeys)
+        metricRowsCompared.Add(tableName, float64(len(batch)+len(deleteKeys)))
+        progressFrom(ctx).addRows(tableName, len(batch)+len(deleteKeys))
+        metricRowsUpdated.Add(tableName, float64(len(batch)))
+        metricRowsDeleted.Add(tableName, float64(len(deleteKeys)))
+        metricChunks.Add(tableName, 1)
+        n := len(batch) + len(deleteKeys)
+        batch, deleteKeys = nil, nil
+        bytes := batchBytes
+        batchBytes = 0
+        return throttleFrom(ctx).consume(ctx, n, bytes)
+    }
+
+    for rows.Next() {
+        var isDeleted bool
+        var key string
+        vals := make([]interface{}, len(columns))
+        ptrs := make([]interface{}, len(vals)+2)
+        ptrs[0], ptrs[1] = &isDeleted, &key
+        for i := range vals {
+            ptrs[i+2] = &vals[i]
+        }
+        if err := rows.Scan(ptrs...); err != nil {
+            return upserted, deleted, err
+        }
+
+        if isDeleted {
+            deleteKeys = append(deleteKeys, key)
+        } else {
+            tr.apply(vals)
+            batch = append(batch, vals)
+            batchBytes += rowBytes(vals)
+        }
+        if len(batch)+len(deleteKeys) >= batchSize {
+            if err := flush(); err != nil {
+                return upserted, deleted, err
+            }
+            if err := checkStop(ctx); err != nil {
+                return upserted, deleted, err
+            }
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return upserted, deleted, err
+    }
+    if err := flush(); err != nil {
+        return upserted, deleted, err
+    }
+    return upserted, deleted, nil
+}
+
+// normalizeIncrementalMode — приводит --incremental и устаревший --use-updated-at к одному значению
+// и проверяет настройки поиска удалений.
+func normalizeIncrementalMode(cfg *Config) error {
+    mode := strings.ToLower(strings.TrimSpace(cfg.IncrementalMode))
+    if mode == "" && cfg.UseUpdatedAt {
+        mode = "updated_at"
+    }
+    switch mode {
+    case "", "updated_at", "xmin", "commit-ts":
+    default:
+        return fmt.Errorf("неизвестный режим incremental: %q (допустимо: updated_at, xmin, commit-ts)", cfg.IncrementalMode)
+    }
+    cfg.IncrementalMode = mode
+    cfg.UseUpdatedAt = mode == "updated_at"
+
+    switch cfg.DeleteDetection {
+    case "none", "antijoin":
+    case "soft":
+        if cfg.SoftDeleteColumn == "" {
+            return fmt.Errorf("delete-detection=soft требует --soft-delete-column")
+        }
+    default:
+        return fmt.Errorf("неизвестный режим delete-detection: %q (допустимо: none, antijoin, soft)", cfg.DeleteDetection)
+    }
+    return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+)
+
+// Защита от параллельных запусков: на standin берётся сессионный advisory lock
+// с ключом (lockNamespace, hashtext('<схема standin>/<задание>')) и держится весь запуск
+// на отдельном соединении. Соединение закрывается вместе с процессом, поэтому
+// упавший pgsyncer блокировку не «залипает».
+
+const (
+    lockNamespace = 0x70677379 // "pgsy": отделяет наши ключи от advisory lock'ов приложения
+    exitLocked    = 3          // код выхода, когда другой экземпляр уже держит блокировку
+)
+
+// errLocked — блокировку держит другой экземпляр pgsyncer
+var errLocked = errors.New("другой экземпляр pgsyncer уже синхронизирует эту схему")
+
+// runLock — удерживаемая блокировка запуска
+type runLock struct {
+    conn *sql.Conn
+    key  string
+}
+
+// acquireRunLock — берёт блокировку (схема, задание). Если она занята: при --lock-wait=0
+// сразу возвращает errLocked, иначе ждёт освобождения не дольше --lock-wait.
+func acquireRunLock(ctx context.Context, cfg *Config, jobName string) (*runLock, error) {
+    key := cfg.StandinSchema + "/" + jobName
+    conn, err := standinDB.Conn(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("соединение для advisory lock: %v", err)
+    }
+
+    deadline := time.Now().Add(cfg.LockWait)
+    waitLogged := false
+    for {
+        var ok bool
+        err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, lockNamespace, key).Scan(&ok)
+        if err != nil {
+            conn.Close()
+            return nil, fmt.Errorf("pg_try_advisory_lock(%s): %v", key, err)
+        }
+        if ok {
+            logFrom(ctx).Debug(T("run lock acquired"), "lock", key)
+            return &runLock{conn: conn, key: key}, nil
+        }
+
+        if cfg.LockWait <= 0 || time.Now().After(deadline) {
+            conn.Close()
+            return nil, fmt.Errorf("%w (блокировка %s)", errLocked, key)
+        }
+        if !waitLogged {
+            logFrom(ctx).Info(T("run lock is held by another instance, waiting"), "lock", key, "timeout", cfg.LockWait)
+            waitLogged = true
+        }
+        select {
+        case <-ctx.Done():
+            conn.Close()
+            return nil, checkStop(ctx)
+        case <-time.After(time.Second):
+        }
+    }
+}
+
+// release — снимает блокировку и возвращает соединение
+func (l *runLock) release() {
+    if l == nil {
+        return
+    }
+    ctx := context.Background()
+    if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1, hashtext($2))`, lockNamespace, l.key); err != nil {
+        logFrom(ctx).Warn(T("failed to release run lock"), "lock", l.key, "error", err)
+    }
+    l.conn.Close()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "log/slog"
+    "os"
+    "strings"
+    "sync"
+)
+
+// Логирование: log/slog с постоянным набором полей.
+//   run_id  — идентификатор одного прохода синхронизации (runSync)
+//   phase   — schema, fdw, data, capture, daemon, metrics
+//   schema, table, worker, chunk_start/chunk_end — где происходит событие
+//   inserted/updated/deleted/rows — счётчики
+// Логгер с накопленными полями едет в context.Context (withLogAttrs / logFrom).
+// Сообщения пишутся по-английски и переводятся через T() по --log-lang. Поля не переводятся,
+// в том числе error: тексты ошибок pgsyncer остаются русскими при любом --log-lang.
+
+// logLang — язык сообщений лога: "en" или "ru"
+var logLang = "en"
+
+type loggerKey struct{}
+
+// initLogging — настраивает slog по --log-format, --log-level и --log-lang
+func initLogging(cfg *Config) error {
+    var level slog.Level
+    switch strings.ToLower(cfg.LogLevel) {
+    case "debug":
+        level = slog.LevelDebug
+    case "info", "":
+        level = slog.LevelInfo
+    case "warn", "warning":
+        level = slog.LevelWarn
+    case "error":
+        level = slog.LevelError
+    default:
+        return fmt.Errorf("неизвестный log-level %q (допустимо: debug, info, warn, error)", cfg.LogLevel)
+    }
+
+    opts := &slog.HandlerOptions{Level: level}
+    var h slog.Handler
+    switch strings.ToLower(cfg.LogFormat) {
+    case "text", "":
+        h = slog.NewTextHandler(termOut, opts)
+    case "json":
+        h = slog.NewJSONHandler(termOut, opts)
+    default:
+        return fmt.Errorf("неизвестный log-format %q (допустимо: text, json)", cfg.LogFormat)
+    }
+
+    switch strings.ToLower(cfg.LogLang) {
+    case "en", "":
+        logLang = "en"
+    case "ru":
+        logLang = "ru"
+    default:
+        return fmt.Errorf("неизвестный log-lang %q (допустимо: en, ru)", cfg.LogLang)
+    }
+
+    slog.SetDefault(slog.New(h))
+    return nil
+}
+
+// T — перевод сообщения лога на язык --log-lang (ключ — английский текст)
+func T(msg string) string {
+    if logLang == "ru" {
+        if tr, ok := messagesRU[msg]; ok {
+            return tr
+        }
+    }
+    return msg
+}
+
+// withLogAttrs — возвращает контекст с логгером, дополненным полями args
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+    return context.WithValue(ctx, loggerKey{}, logFrom(ctx).With(args...))
+}
+
+// logFrom — логгер из контекста (или slog.Default, если его там нет)
+func logFrom(ctx context.Context) *slog.Logger {
+    if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+        return l
+    }
+    return slog.Default()
+}
+
+// newRunID — короткий случайный идентификатор прохода синхронизации
+func newRunID() string {
+    b := make([]byte, 6)
+    if _, err := rand.Read(b); err != nil {
+        return "unknown"
+    }
+    return hex.EncodeToString(b)
+}
+
+// fatal — пишет ошибку в лог и завершает процесс с кодом 1
+func fatal(ctx context.Context, msg string, args ...any) {
+    logFrom(ctx).Error(T(msg), args...)
+    os.Exit(1)
+}
+
+// termOut — stderr для логов и блока прогресса на терминале.
+// Перед строкой лога блок стирается и затем рисуется заново, чтобы они не перемешивались.
+var termOut = &termWriter{}
+
+type termWriter struct {
+    mu     sync.Mutex
+    blocks []termBlock // блоки в порядке появления (у каждого задания daemon — свой)
+    drawn  int         // сколько строк блоков сейчас на экране
+}
+
+type termBlock struct {
+    owner interface{} // трекер прогресса
+    lines []string
+}
+
+func (w *termWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.clear()
+    n, err := os.Stderr.Write(p)
+    w.draw()
+    return n, err
+}
+
+// setBlock — заменяет строки блока владельца owner (nil — убрать блок)
+func (w *termWriter) setBlock(owner interface{}, lines []string) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.clear()
+    defer w.draw()
+    for i, b := range w.blocks {
+        if b.owner != owner {
+            continue
+        }
+        if lines == nil {
+            w.blocks = append(w.blocks[:i], w.blocks[i+1:]...)
+        } else {
+            w.blocks[i].lines = lines
+        }
+        return
+    }
+    if lines != nil {
+        w.blocks = append(w.blocks, termBlock{owner: owner, lines: lines})
+    }
+}
+
+func (w *termWriter) clear() {
+    if w.drawn > 0 {
+        fmt.Fprintf(os.Stderr, "\033[%dA\033[J", w.drawn)
+        w.drawn = 0
+    }
+}
+
+func (w *termWriter) draw() {
+    for _, b := range w.blocks {
+        for _, l := range b.lines {
+            fmt.Fprintln(os.Stderr, l)
+        }
+        w.drawn += len(b.lines)
+    }
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+    "context"
     "database/sql"
+    "errors"
     "fmt"
     "log"
+    "log/slog"
     "os"
     "os/exec"
+    "os/signal"
+    "strconv"
+    "syscall"
+    "time"
 
-    _ "github.com/jackc/pgx/v5/stdlib"
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/stdlib"
 )
 
 var (
@@ -18,56 +26,166 @@ var (
 func main() {
     // 1) Считываем конфиг
     cfg := ParseConfigFromFlags()
+    if err := initLogging(cfg); err != nil {
+        log.Fatalf("%v", err)
+    }
+
+    // Корневой контекст: SIGINT/SIGTERM отменяют его, и синхронизация останавливается
+    // на ближайшей границе чанка. Повторный сигнал — обычное завершение процесса.
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+    go func() {
+        <-ctx.Done()
+        slog.Info(T("stop signal received, finishing current chunks (second signal aborts)"))
+        stop()
+    }()
 
     // 2) Подключаемся к БД
     var err error
-    mainDB, err = sql.Open("pgx", cfg.MainDSN)
+    mainDB, err = openDB(cfg.MainDSN, cfg.StatementTimeout)
     if err != nil {
-        log.Fatalf("Ошибка подключения к main DB: %v", err)
+        fatal(ctx, "main DB connection failed", "error", err)
     }
     defer mainDB.Close()
 
-    standinDB, err = sql.Open("pgx", cfg.StandinDSN)
-    if err != nil {
-        log.Fatalf("Ошибка подключения к standin DB: %v", err)
+    // export только читает main: standin ему не нужен (standinDB остаётся nil)
+    needStandin := cfg.Command != "export"
+    if needStandin {
+        standinDB, err = openDB(cfg.StandinDSN, cfg.StatementTimeout)
+        if err != nil {
+            fatal(ctx, "standin DB connection failed", "error", err)
+        }
+        defer standinDB.Close()
     }
-    defer standinDB.Close()
 
     // Проверим работоспособность
-    if err := mainDB.Ping(); err != nil {
-        log.Fatalf("Ping mainDB: %v", err)
+    if err := mainDB.PingContext(ctx); err != nil {
+        fatal(ctx, "main DB ping failed", "error", err)
     }
-    if err := standinDB.Ping(); err != nil {
-        log.Fatalf("Ping standinDB: %v", err)
+    if needStandin {
+        if err := standinDB.PingContext(ctx); err != nil {
+            fatal(ctx, "standin DB ping failed", "error", err)
+        }
+    }
+
+    // Ограничения нагрузки (--max-rows-per-sec, --max-replica-lag, ...) общие для всех воркеров и заданий
+    ctx = withThrottle(ctx, newThrottle(cfg))
+    ctx = withRetryPolicy(ctx, newRetryPolicy(cfg))
+
+    if cfg.MetricsAddr != "" {
+        startMetricsServer(cfg.MetricsAddr)
     }
 
     // 3) Убедимся, что pg_dump доступен
     if _, err := exec.LookPath(cfg.PgDumpPath); err != nil {
-        log.Printf("[WARN] pg_dump не найден в PATH: %v", err)
+        slog.Warn(T("pg_dump not found in PATH"), "error", err)
         // если критично — можно сделать fatal
     }
 
+    // 4) Блокировка от параллельных запусков. Daemon берёт свою на каждое задание.
+    // Снимается при выходе из main; если процесс завершился по fatal, сервер снимает
+    // её вместе с сессией. Блокировка живёт в standin, поэтому export её не берёт: он ничего не меняет.
+    var lock *runLock
+    if cfg.Command != "daemon" && needStandin {
+        if lock, err = acquireRunLock(ctx, cfg, cfg.JobName); err != nil {
+            exitIfLocked(ctx, err)
+            fatal(ctx, "run lock failed", "error", err)
+        }
+    }
+    defer lock.release()
+
+    switch cfg.Command {
+    case "sync":
+        if err := runSync(ctx, cfg); err != nil {
+            fatal(ctx, "sync failed", "error", err)
+        }
+        slog.Info(T("sync finished"))
+    case "daemon":
+        // Пулы соединений живут всё время работы демона
+        if err := runDaemon(ctx, cfg); err != nil {
+            exitIfLocked(ctx, err)
+            fatal(ctx, "daemon failed", "error", err)
+        }
+    case "uninstall":
+        if err := uninstallCapture(ctx, cfg); err != nil {
+            fatal(ctx, "uninstall failed", "error", err)
+        }
+        slog.Info(T("pgsyncer objects removed"))
+    case "purge":
+        if err := purgeTrash(ctx, cfg); err != nil {
+            fatal(ctx, "purge failed", "error", err)
+        }
+    case "subset":
+        if err := runSubset(ctx, cfg); err != nil {
+            fatal(ctx, "subset failed", "error", err)
+        }
+        slog.Info(T("subset finished"))
+    case "export":
+        if err := runExport(ctx, cfg); err != nil {
+            fatal(ctx, "export failed", "error", err)
+        }
+    default:
+        fatal(ctx, "unknown command (valid: sync, daemon, uninstall, purge, subset, export)", "command", cfg.Command)
+    }
+}
+
+// exitIfLocked — завершает процесс с кодом exitLocked, если блокировку держит другой экземпляр
+func exitIfLocked(ctx context.Context, err error) {
+    if errors.Is(err, errLocked) {
+        logFrom(ctx).Warn(T("another pgsyncer instance is running, exiting"), "error", err, "exit_code", exitLocked)
+        os.Exit(exitLocked)
+    }
+}
+
+// runSync — один полный проход синхронизации: FDW, структура, данные.
+// Используется и разовым запуском, и каждым срабатыванием задания в daemon-режиме.
+func runSync(ctx context.Context, cfg *Config) (err error) {
+    runID := newRunID()
+    ctx = withLogAttrs(ctx, "run_id", runID)
+    // Отчёт о проходе: несинхронизированные чанки и таблицы (см. report.go)
+    report := newRunReport(runID, cfg.JobName)
+    ctx = withRunReport(ctx, report)
+    defer func() { report.finish(ctx, cfg, err) }()
     // 4) Если включён FDWMode — настраиваем fdw
     if cfg.FDWMode {
-        if err := setupFDW(cfg); err != nil {
-            log.Fatalf("setupFDW ошибка: %v", err)
+        if err := setupFDW(ctx, cfg); err != nil {
+            metricErrors.Add("fdw", 1)
+            return fmt.Errorf("setupFDW ошибка: %w", err)
         }
     }
 
     // 5) Синхронизация структуры (DDL)
     if cfg.SyncSchema {
-        if err := SyncSchema(cfg); err != nil {
-            log.Fatalf("SyncSchema ошибка: %v", err)
+        if err := SyncSchema(ctx, cfg); err != nil {
+            metricErrors.Add("schema", 1)
+            return fmt.Errorf("SyncSchema ошибка: %w", err)
         }
     }
 
     // 6) Синхронизация данных
     if cfg.SyncData {
-        if err := SyncData(cfg); err != nil {
-            log.Fatalf("SyncData ошибка: %v", err)
+        // Trigger-захват ставим до открытия снимка: всё, что изменится позже, попадёт в журнал
+        if cfg.CaptureMode == "trigger" {
+            if err := installCapture(ctx, cfg); err != nil {
+                return fmt.Errorf("installCapture ошибка: %w", err)
+            }
+        }
+
+        if err := SyncData(ctx, cfg); err != nil {
+            return fmt.Errorf("SyncData ошибка: %w", err)
         }
     }
+    return nil
+}
 
-    fmt.Println("Синхронизация завершена.")
-    os.Exit(0)
+// openDB — открывает пул соединений; statement_timeout (если задан) передаётся
+// серверу параметром соединения и ограничивает каждый отдельный запрос.
+func openDB(dsn string, statementTimeout time.Duration) (*sql.DB, error) {
+    connCfg, err := pgx.ParseConfig(dsn)
+    if err != nil {
+        return nil, err
+    }
+    if statementTimeout > 0 {
+        connCfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(statementTimeout.Milliseconds(), 10)
+    }
+    return stdlib.OpenDB(*connCfg), nil
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// Переименование схемы и таблиц на стороне standin: --standin-schema replica_public
+// и --table-map orders=orders_mirror. Так одна резервная БД держит рядом копии
+// нескольких main. Все запросы к main используют исходные имена, все запросы
+// к standin (чтение, upsert, удаление, clean-extra, FDW, sync_state) — отображённые.
+
+// tableMap — переименования --table-map: таблица main -> таблица standin
+type tableMap map[string]string
+
+// parseTableMap — разбирает значения --table-map "main=standin[,main2=standin2]"
+func parseTableMap(specs []string) (tableMap, error) {
+    out := make(tableMap)
+    targets := make(map[string]string)
+    for _, spec := range specs {
+        for _, pair := range splitList(spec) {
+            eq := strings.Index(pair, "=")
+            if eq <= 0 || strings.TrimSpace(pair[eq+1:]) == "" {
+                return nil, fmt.Errorf("неверное отображение таблицы %q (ожидается main=standin)", pair)
+            }
+            from, to := strings.TrimSpace(pair[:eq]), strings.TrimSpace(pair[eq+1:])
+            if _, dup := out[from]; dup {
+                return nil, fmt.Errorf("отображение для таблицы %s задано дважды", from)
+            }
+            if prev, dup := targets[to]; dup {
+                return nil, fmt.Errorf("таблицы %s и %s отображаются в одну таблицу standin %s", prev, from, to)
+            }
+            out[from], targets[to] = to, from
+        }
+    }
+    return out, nil
+}
+
+// standinTable — имя таблицы main в standin
+func standinTable(cfg *Config, table string) string {
+    if t, ok := cfg.TableMap[table]; ok {
+        return t
+    }
+    return table
+}
+
+// mainTableFor — обратное отображение: таблица main для таблицы standin
+func mainTableFor(cfg *Config, table string) string {
+    for from, to := range cfg.TableMap {
+        if to == table {
+            return from
+        }
+    }
+    return table
+}
+
+// remapped — отличаются ли имена на standin от имён main
+func remapped(cfg *Config) bool {
+    return cfg.StandinSchema != cfg.Schema || len(cfg.TableMap) > 0
+}
+
+// remapDDL — переписывает DDL pg_dump на имена standin: квалифицированные имена
+// <схема>.<объект> и упоминания самой схемы (CREATE/ALTER/COMMENT ON SCHEMA).
+// Замена текстовая: строковые литералы и тела функций с такими именами тоже изменятся.
+// Имена из extMembers (объекты расширений, см. extensionMembers) остаются как есть:
+// расширение установлено в свою схему, и в схеме standin таких объектов нет.
+func remapDDL(cfg *Config, ddl string, extMembers map[string]bool) string {
+    if !remapped(cfg) {
+        return ddl
+    }
+    schema := regexp.QuoteMeta(cfg.Schema)
+    qualified := regexp.MustCompile(`(^|[^\w."$])(?:"` + schema + `"|` + schema + `)\.("(?:[^"]|"")+"|[A-Za-z_][\w$]*)`)
+    ddl = qualified.ReplaceAllStringFunc(ddl, func(m string) string {
+        sub := qualified.FindStringSubmatch(m)
+        name := strings.Trim(sub[2], `"`)
+        if extMembers[name] {
+            return m
+        }
+        return fmt.Sprintf(`%s"%s"."%s"`, sub[1], cfg.StandinSchema, standinTable(cfg, name))
+    })
+    schemaStmt := regexp.MustCompile(`(?i)\b(SCHEMA\s+)(?:"` + schema + `"|` + schema + `)(\s|;)`)
+    return schemaStmt.ReplaceAllString(ddl, `${1}"`+cfg.StandinSchema+`"${2}`)
+}
+
+// extensionMembers — имена объектов схемы main, принадлежащих расширениям (pg_depend, deptype 'e'):
+// функции, типы, таблицы и последовательности, правила сортировки, конфигурации поиска.
+// Например, public.uuid_generate_v4() и public.citext при --standin-schema переписывать нельзя.
+func extensionMembers(ctx context.Context, schema string) (map[string]bool, error) {
+    q := `
+WITH ext AS (SELECT classid, objid FROM pg_depend WHERE deptype = 'e'),
+     ns AS (SELECT oid FROM pg_namespace WHERE nspname = $1)
+SELECT p.proname FROM pg_proc p JOIN ext e ON e.classid = 'pg_proc'::regclass AND e.objid = p.oid
+WHERE p.pronamespace = (SELECT oid FROM ns)
+UNION
+SELECT t.typname FROM pg_type t JOIN ext e ON e.classid = 'pg_type'::regclass AND e.objid = t.oid
+WHERE t.typnamespace = (SELECT oid FROM ns)
+UNION
+SELECT c.relname FROM pg_class c JOIN ext e ON e.classid = 'pg_class'::regclass AND e.objid = c.oid
+WHERE c.relnamespace = (SELECT oid FROM ns)
+UNION
+SELECT co.collname FROM pg_collation co JOIN ext e ON e.classid = 'pg_collation'::regclass AND e.objid = co.oid
+WHERE co.collnamespace = (SELECT oid FROM ns)
+UNION
+SELECT ts.cfgname FROM pg_ts_config ts JOIN ext e ON e.classid = 'pg_ts_config'::regclass AND e.objid = ts.oid
+WHERE ts.cfgnamespace = (SELECT oid FROM ns)`
+    rows, err := mainDB.QueryContext(ctx, q, schema)
+    if err != nil {
+        return nil, fmt.Errorf("объекты расширений в схеме %s: %v", schema, err)
+    }
+    defer rows.Close()
+    out := make(map[string]bool)
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            return nil, err
+        }
+        out[name] = true
+    }
+    return out, rows.Err()
+}
@@ -0,0 +1,194 @@
+package main
+
+// messagesRU — переводы сообщений лога для --log-lang=ru.
+// Ключ — английский текст, который передаётся в T(). Поля (table, error, ...) не переводятся:
+// тексты ошибок в поле error остаются русскими и при --log-lang=en.
+// Сообщение без перевода выводится по-английски.
+var messagesRU = map[string]string{
+    // main
+    "stop signal received, finishing current chunks (second signal aborts)": "Получен сигнал остановки — завершаем текущие чанки (повторный сигнал прервёт процесс)",
+    "main DB connection failed":                     "Ошибка подключения к main DB",
+    "standin DB connection failed":                  "Ошибка подключения к standin DB",
+    "main DB ping failed":                           "Ping main DB не прошёл",
+    "standin DB ping failed":                        "Ping standin DB не прошёл",
+    "pg_dump not found in PATH":                     "pg_dump не найден в PATH",
+    "sync failed":                                   "Синхронизация завершилась с ошибкой",
+    "sync finished":                                 "Синхронизация завершена",
+    "daemon failed":                                 "Ошибка daemon-режима",
+    "uninstall failed":                              "Ошибка uninstall",
+    "pgsyncer objects removed":                      "Объекты pgsyncer удалены",
+    "unknown command (valid: sync, daemon, uninstall, purge, subset, export)": "Неизвестная команда (допустимо: sync, daemon, uninstall, purge, subset, export)",
+    "run lock failed":                               "Не удалось взять блокировку запуска",
+    "another pgsyncer instance is running, exiting": "Уже работает другой экземпляр pgsyncer, выходим",
+    "run lock acquired":                             "Блокировка запуска получена",
+    "run lock is held by another instance, waiting": "Блокировку держит другой экземпляр, ждём",
+    "failed to release run lock":                    "Не удалось снять блокировку запуска",
+    "purge failed":                                  "Ошибка purge",
+    "subset failed":                                 "Ошибка subset",
+    "subset finished":                               "Подмножество скопировано",
+
+    // schema
+    "schema sync started":                                       "Синхронизация структуры...",
+    "running pg_dump":                                           "Выполняем pg_dump",
+    "pg_dump returned empty output, schema may have no objects": "pg_dump вернул пустой результат, возможно, в схеме нет объектов",
+    "DDL received":                                              "Получен DDL",
+    "applying DDL via psql":                                     "Применяем DDL через psql",
+    "applying DDL statement by statement":                       "Применяем DDL по одному выражению",
+    "schema sync finished":                                      "Структура синхронизирована",
+    "DDL statement failed":                                      "Ошибка выполнения DDL",
+    "psql output":                                               "Вывод psql",
+
+    // fdw
+    "postgres_fdw extension created (if missing)": "Расширение postgres_fdw создано (если не было)",
+    "creating foreign server":                     "Создаём сервер",
+    "creating user mapping":                       "Создаём USER MAPPING",
+    "importing foreign schema":                    "IMPORT FOREIGN SCHEMA",
+    "foreign schema import failed":                "Не удалось импортировать схему",
+    "table synced via FDW":                        "Таблица синхронизирована через FDW",
+    "postgres_fdw configured":                     "postgres_fdw настроен",
+
+    // data
+    "data sync started":                           "Начало синхронизации данных",
+    "tables found in main":                        "Найдены таблицы в основной БД",
+    "no tables in main, nothing to do":            "В основной БД нет таблиц для обработки",
+    "dropping extra standin tables (clean-extra)": "Удаление лишних таблиц включено (clean-extra)",
+    "tables selected":                             "Выбраны таблицы задания",
+    "starting workers":                            "Запускаем воркеры",
+    "table sync started":                          "Начало синхронизации таблицы",
+    "table sync failed":                           "Ошибка синхронизации таблицы",
+    "table sync finished":                         "Таблица синхронизирована",
+    "worker finished, no more tables":             "Воркер завершён (таблицы закончились)",
+    "run interrupted":                             "Запуск прерван",
+    "data sync finished":                          "Синхронизация данных успешно завершена",
+    "failed to drop extra table":                  "Не смогли удалить лишнюю таблицу",
+    "dropped table missing in main":               "Удалена таблица, которой нет в main",
+
+    // quarantine
+    "failed to quarantine extra table":       "Не смогли перенести лишнюю таблицу в карантин",
+    "extra table moved to quarantine":        "Лишняя таблица перенесена в карантин",
+    "failed to list dependent objects":       "Не удалось получить зависимые объекты",
+    "dependent objects CASCADE would remove": "Зависимые объекты, которые удалит CASCADE",
+    "not a quarantined table, skipping":      "Таблица не из карантина, пропускаем",
+    "quarantined table purged":               "Таблица удалена из карантина",
+    "trash purge finished":                   "Очистка карантина завершена",
+
+    // partitions
+    "partition created on standin":          "Секция создана в standin",
+    "partition attached on standin":         "Секция подключена в standin",
+    "partition detached on standin":         "Секция отсоединена в standin",
+    "detached partition dropped on standin": "Отсоединённая секция удалена из standin",
+
+    // reload
+    "reloading table via staging copy":                               "Перезаливка таблицы через staging-копию",
+    "reload does not support partitioned tables, using regular sync": "Перезаливка не поддерживает секционированные таблицы, обычная синхронизация",
+    "failed to drop staging table":                                   "Не удалось удалить staging-таблицу",
+    "table swapped":                                                  "Таблица подменена",
+    "foreign key validation failed after reload":                     "Проверка внешнего ключа после перезаливки не прошла",
+
+    // bulk load
+    "bulk load: empty standin table, indexes and triggers deferred": "Первичная заливка: таблица standin пуста, индексы и триггеры отложены",
+    "bulk load: indexes rebuilt, table analyzed":                    "Первичная заливка: индексы построены, выполнен ANALYZE",
+    "restoring indexes and triggers left by interrupted bulk load":  "Восстановление индексов и триггеров после прерванной первичной заливки",
+    "bulk load restore failed":                                      "Не удалось восстановить индексы и триггеры после первичной заливки",
+
+    // filter
+    "rows outside filter purged": "Удалены строки вне фильтра",
+
+    // subset
+    "subset extraction started":         "Сборка подмножества",
+    "subset root rows selected":         "Выбраны корневые строки подмножества",
+    "subset rows added via foreign key": "Строки добавлены в подмножество по внешнему ключу",
+    "subset expansion pass finished":    "Проход расширения подмножества завершён",
+    "foreign key cycle, loading these tables with deferred constraints": "Цикл внешних ключей, эти таблицы загружаются с отложенными ограничениями",
+    "subset table copied":               "Таблица подмножества скопирована",
+    "subset copied to standin":          "Подмножество скопировано в standin",
+
+    // columns
+    "column added to standin":                           "Столбец добавлен в standin",
+    "column types differ, consider --column-cast":       "Типы столбца различаются, задайте --column-cast",
+    "columns missing in standin, skipping them":         "В standin нет столбцов main, пропускаем их",
+    "generated and excluded columns skipped":            "Пропущены вычисляемые и исключённые столбцы",
+    "standin has extra columns, leaving them untouched": "В standin есть лишние столбцы, не трогаем их",
+
+    // progress
+    "table size estimate failed, progress without ETA": "Не удалось оценить размер таблиц, прогресс без ETA",
+    "table progress":                                   "Прогресс таблицы",
+    "overall progress":                                 "Общий прогресс",
+
+    // table
+    "table has no PK, rows cannot be matched, skipping":    "Таблица без PK — строки не сопоставить, пропускаем",
+    "capture not initialized yet, running full sync":       "Таблица ещё не инициализирована — выполняем полную синхронизацию",
+    "initial sync incomplete, capture stays uninitialized": "Начальная синхронизация неполная — захват остаётся неинициализированным, журнал не очищен",
+    "table has no PK (or not found), using full diff":      "Таблица не имеет PK (или не найдена), используем полный дифф",
+    "composite or non-numeric PK, using full diff":         "Составной/строковый PK, переходим на полный дифф",
+    "PK lookup failed":                                     "Ошибка при запросе PK",
+    "PK scan failed":                                       "Ошибка Scan PK",
+    "composite PK, treating as non-numeric":                "Составной PK, считаем numericPK=false",
+    "PK column type lookup failed":                         "Ошибка при чтении типа столбца PK",
+    "PK detected":                                          "Определён PK",
+    "table has no columns, skipping":                       "Таблица не имеет столбцов, пропускаем",
+    "table is empty, skipping":                             "Таблица пуста, пропускаем",
+    "chunked sync":                                         "Синхронизация чанками",
+    "chunk size adjusted":                                  "Размер чанка изменён",
+    "large table split into parts":                         "Большая таблица разделена на части",
+    "resuming interrupted run":                             "Продолжаем прерванный запуск",
+    "stopping before chunk":                                "Остановка перед чанком",
+    "failed to record resume point":                        "Не удалось записать точку остановки",
+    "failed to read chunk from main":                       "Ошибка чтения чанка из main",
+    "failed to read chunk from standin":                    "Ошибка чтения чанка из standin",
+    "failed to apply chunk":                                "Ошибка применения чанка",
+    "chunk applied":                                        "Чанк применён",
+    "failed to clear resume point":                         "Не удалось сбросить точку остановки",
+    "batch delete failed":                                  "Ошибка пакетного DELETE",
+    "changes committed":                                    "Изменения закоммичены",
+    "full diff":                                            "Полный дифф",
+
+    // incremental, deletes
+    "table has no PK, upsert impossible, using full diff":           "Таблица без PK — upsert невозможен, полный дифф",
+    "table has no PK, incremental mode impossible, using full diff": "Таблица без PK — инкрементальный режим невозможен, полный дифф",
+    "no saved horizon, running full sync":                           "Нет сохранённого горизонта, выполняем полную синхронизацию",
+    "initial sync incomplete, horizon not saved":                    "Начальная синхронизация неполная — горизонт не сохранён",
+    "changed rows transferred":                                      "Перенесены изменённые строки",
+    "table has no PK, anti-join impossible, skipping":               "Таблица без PK — анти-join невозможен, пропускаем",
+    "anti-join ran recently, skipping":                              "Анти-join был недавно, пропускаем",
+    "rows deleted":                                                  "Удалены строки",
+
+    // capture
+    "table has no PK, trigger capture impossible, skipping": "Таблица без PK — trigger-захват невозможен, пропускаем",
+    "trigger capture installed":                             "Trigger-захват установлен",
+    "capture triggers removed":                              "Сняты триггеры захвата",
+    "schema not dropped (not empty?)":                       "Схема не удалена (не пуста?)",
+    "trigger capture uninstalled":                           "Trigger-захват удалён",
+    "changelog applied":                                     "Применён журнал изменений",
+
+    // daemon, metrics
+    "daemon started":                        "Daemon запущен",
+    "all jobs stopped":                      "Все задания остановлены",
+    "schedule has no next run, job stopped": "Расписание не даёт следующего запуска, задание остановлено",
+    "next run scheduled":                    "Следующий запуск",
+    "job started":                           "Задание стартовало",
+    "job stopped by signal":                 "Задание остановлено по сигналу",
+    "job failed":                            "Задание завершилось с ошибкой",
+    "job finished":                          "Задание выполнено",
+    "metrics endpoint listening":            "Метрики доступны",
+    "metrics server stopped":                "Сервер метрик остановлен",
+
+    // throttling
+    "throttle check failed":                          "Не удалось проверить нагрузку, продолжаем без паузы",
+    "load too high, pausing chunk processing":        "Нагрузка выше порога, обработка чанков приостановлена",
+    "load back to normal, resuming chunk processing": "Нагрузка в норме, обработка чанков продолжается",
+
+    // retry, run report
+    "transient error, retrying":                         "Временная ошибка, повторяем",
+    "run report: some chunks or tables were not synced": "Отчёт о проходе: часть чанков или таблиц не синхронизирована",
+    "failed to write run report":                        "Не удалось записать отчёт о проходе",
+    "run report written":                                "Отчёт о проходе записан",
+
+    // export
+    "export started":        "Выгрузка начата",
+    "table export started":  "Выгрузка таблицы начата",
+    "table export finished": "Таблица выгружена",
+    "table export failed":   "Ошибка выгрузки таблицы",
+    "export finished":       "Выгрузка завершена",
+    "export failed":         "Ошибка выгрузки",
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "log/slog"
+    "math"
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Метрики отдаются в текстовом формате Prometheus (exposition format 0.0.4).
+// Своя минимальная реализация, чтобы не тянуть client_golang ради десятка серий:
+// у каждой метрики не больше одной метки (table или phase).
+
+// metricVec — counter или gauge с одной меткой
+type metricVec struct {
+    name, help, typ string
+    label           string // имя метки, "" — метрика без меток
+
+    mu     sync.Mutex
+    values map[string]float64
+}
+
+func newMetricVec(typ, name, help, label string) *metricVec {
+    m := &metricVec{name: name, help: help, typ: typ, label: label, values: make(map[string]float64)}
+    registerMetric(m)
+    return m
+}
+
+// Add — прибавляет v к серии с меткой lv
+func (m *metricVec) Add(lv string, v float64) {
+    m.mu.Lock()
+    m.values[lv] += v
+    m.mu.Unlock()
+}
+
+// Set — устанавливает значение серии (для gauge)
+func (m *metricVec) Set(lv string, v float64) {
+    m.mu.Lock()
+    m.values[lv] = v
+    m.mu.Unlock()
+}
+
+func (m *metricVec) write(w io.Writer) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+    for _, lv := range sortedKeys(m.values) {
+        fmt.Fprintf(w, "%s%s %s\n", m.name, labelPair(m.label, lv, ""), formatFloat(m.values[lv]))
+    }
+}
+
+// histogramVec — гистограмма с одной меткой и фиксированными границами корзин
+type histogramVec struct {
+    name, help string
+    label      string
+    buckets    []float64
+
+    mu     sync.Mutex
+    series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+    counts []uint64 // по корзинам, не накопительно
+    count  uint64
+    sum    float64
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+    h := &histogramVec{name: name, help: help, label: label, buckets: buckets, series: make(map[string]*histogramSeries)}
+    registerMetric(h)
+    return h
+}
+
+// Observe — добавляет наблюдение v в серию с меткой lv
+func (h *histogramVec) Observe(lv string, v float64) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    s, ok := h.series[lv]
+    if !ok {
+        s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+        h.series[lv] = s
+    }
+    for i, b := range h.buckets {
+        if v <= b {
+            s.counts[i]++
+            break
+        }
+    }
+    s.count++
+    s.sum += v
+}
+
+func (h *histogramVec) write(w io.Writer) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+    keys := make([]string, 0, len(h.series))
+    for k := range h.series {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+
+    for _, lv := range keys {
+        s := h.series[lv]
+        var cum uint64
+        for i, b := range h.buckets {
+            cum += s.counts[i]
+            fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPair(h.label, lv, formatFloat(b)), cum)
+        }
+        fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPair(h.label, lv, "+Inf"), s.count)
+        fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPair(h.label, lv, ""), formatFloat(s.sum))
+        fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPair(h.label, lv, ""), s.count)
+    }
+}
+
+type metricWriter interface {
+    write(w io.Writer)
+}
+
+var (
+    registryMu sync.Mutex
+    registry   []metricWriter
+)
+
+func registerMetric(m metricWriter) {
+    registryMu.Lock()
+    registry = append(registry, m)
+    registryMu.Unlock()
+}
+
+// Метрики синхронизации
+var (
+    durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+    metricRowsCompared = newMetricVec("counter", "pgsyncer_rows_compared_total", "Rows read from main and compared with standin.", "table")
+    metricRowsInserted = newMetricVec("counter", "pgsyncer_rows_inserted_total", "Rows inserted into standin.", "table")
+    metricRowsUpdated  = newMetricVec("counter", "pgsyncer_rows_updated_total", "Rows updated in standin (upserts of changed keys included).", "table")
+    metricRowsDeleted  = newMetricVec("counter", "pgsyncer_rows_deleted_total", "Rows deleted from standin.", "table")
+    metricChunks       = newMetricVec("counter", "pgsyncer_chunks_processed_total", "Chunks (or apply batches) processed.", "table")
+    metricErrors       = newMetricVec("counter", "pgsyncer_errors_total", "Errors by phase (schema, fdw, data).", "phase")
+    metricLastSuccess  = newMetricVec("gauge", "pgsyncer_last_success_timestamp_seconds", "Unix time of the last successful sync of the table.", "table")
+    metricWorkers      = newMetricVec("gauge", "pgsyncer_active_workers", "Workers currently syncing a table.", "")
+    metricChunkSize    = newMetricVec("gauge", "pgsyncer_chunk_size", "Current chunk size (PK range width) of the table.", "table")
+    metricRetries      = newMetricVec("counter", "pgsyncer_retries_total", "Retries after transient errors, by SQLSTATE (empty for network errors).", "sqlstate")
+    metricThrottleWait = newMetricVec("counter", "pgsyncer_throttle_wait_seconds_total", "Time spent waiting on throttling, by reason (rows, bytes, queries, replica_lag, main_connections).", "reason")
+
+    metricChunkDuration = newHistogramVec("pgsyncer_chunk_duration_seconds", "Duration of one chunk (read, compare, apply).", "table", durationBuckets)
+    metricTableDuration = newHistogramVec("pgsyncer_table_duration_seconds", "Duration of one table sync.", "table", durationBuckets)
+)
+
+// observeSince — записывает в гистограмму время, прошедшее с start
+func observeSince(h *histogramVec, lv string, start time.Time) {
+    h.Observe(lv, time.Since(start).Seconds())
+}
+
+// startMetricsServer — поднимает HTTP-листенер с /metrics (если задан --metrics-addr)
+func startMetricsServer(addr string) {
+    metricWorkers.Set("", 0)
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        registryMu.Lock()
+        defer registryMu.Unlock()
+        for _, m := range registry {
+            m.write(w)
+        }
+    })
+
+    go func() {
+        l := slog.With("phase", "metrics")
+        l.Info(T("metrics endpoint listening"), "url", "http://"+addr+"/metrics")
+        if err := http.ListenAndServe(addr, mux); err != nil {
+            l.Warn(T("metrics server stopped"), "error", err)
+        }
+    }()
+}
+
+// labelPair — `{label="value"}` (+ le для гистограмм); пустая строка, если меток нет
+func labelPair(label, value, le string) string {
+    var parts []string
+    if label != "" {
+        parts = append(parts, fmt.Sprintf(`%s="%s"`, label, escapeLabel(value)))
+    }
+    if le != "" {
+        parts = append(parts, fmt.Sprintf(`le="%s"`, le))
+    }
+    if len(parts) == 0 {
+        return ""
+    }
+    return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+    v = strings.ReplaceAll(v, `\`, `\\`)
+    v = strings.ReplaceAll(v, "\n", `\n`)
+    return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+    if math.IsInf(v, +1) {
+        return "+Inf"
+    }
+    return fmt.Sprintf("%g", v)
+}
+
+func sortedKeys(m map[string]float64) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
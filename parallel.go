@@ -0,0 +1,292 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// Параллельная синхронизация. Снимок main один на весь проход: mainTx экспортирует его
+// (pg_export_snapshot), а каждый воркер открывает свою транзакцию REPEATABLE READ
+// и импортирует тот же снимок (SET TRANSACTION SNAPSHOT). Так воркеры читают main
+// одновременно, по своим соединениям, и видят одни и те же данные. Транзакции воркеров
+// фиксируются только после успешного завершения всех таблиц (как и раньше mainTx):
+// иначе записи журнала trigger-захвата остаются до следующего запуска.
+//
+// Очень большие таблицы (оценка не меньше --parallel-table-rows) с числовым PK делятся
+// на части — диапазоны PK, которые попадают в общую очередь вместе с остальными
+// таблицами, так что одну таблицу проходят несколько воркеров. Что привязано к таблице
+// целиком, делается один раз: подготовка (столбцы, --bulk-load) — первой взятой частью,
+// завершение (--filter-purge, восстановление индексов, метрики) — последней.
+// При остановке точка --resume — последний PK, до которого все части пройдены.
+
+// tablePartsPerWorker — сколько частей на воркер: части разной плотности
+// выравниваются по воркерам, пока очередь не опустеет
+const tablePartsPerWorker = 4
+
+// workItem — элемент общей очереди воркеров: таблица целиком или часть большой таблицы
+type workItem struct {
+    table string
+    run   *tableRun // nil — таблица целиком
+    part  int
+}
+
+// tablePart — диапазон PK части таблицы и докуда он пройден
+type tablePart struct {
+    start, end int64
+    lastDone   int64
+    done       bool
+}
+
+// tableRun — большая таблица, которую параллельно проходят несколько воркеров
+type tableRun struct {
+    cfg   *Config
+    table string
+    pkCol string
+    parts []tablePart
+
+    startOnce sync.Once
+    ctx       context.Context // контекст таблицы: таймаут (для запросов к standin и checkStop), защита удалений, прогресс
+    cancel    context.CancelFunc
+    plan      *chunkPlan
+    bl        *bulkLoad
+    startErr  error
+    started   time.Time
+
+    mu        sync.Mutex
+    remaining int
+    stopped   bool
+    err       error
+    finished  bool
+}
+
+// exportSnapshot — идентификатор снимка mainTx для транзакций воркеров
+func exportSnapshot(ctx context.Context, mainTx *sql.Tx) (string, error) {
+    var id string
+    if err := mainTx.QueryRowContext(snapshotContext(ctx), `SELECT pg_export_snapshot()`).Scan(&id); err != nil {
+        return "", fmt.Errorf("pg_export_snapshot: %v", err)
+    }
+    return id, nil
+}
+
+// beginSnapshotTx — транзакция воркера в снимке snapshot (экспортирующая mainTx должна быть открыта).
+// Транзакцию воркера делят все его таблицы и части, поэтому ни остановка, ни таймаут одной
+// таблицы её не обрывают (см. snapshotContext).
+func beginSnapshotTx(ctx context.Context, snapshot string) (*sql.Tx, error) {
+    sctx := snapshotContext(ctx)
+    tx, err := mainDB.BeginTx(sctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+    if err != nil {
+        return nil, fmt.Errorf("BeginTx mainDB: %v", err)
+    }
+    // SET TRANSACTION SNAPSHOT должен идти первым в транзакции
+    if _, err := tx.ExecContext(sctx, fmt.Sprintf(`SET TRANSACTION SNAPSHOT '%s'`, snapshot)); err != nil {
+        tx.Rollback()
+        return nil, fmt.Errorf("SET TRANSACTION SNAPSHOT: %v", err)
+    }
+    if err := disableStatementTimeout(ctx, tx); err != nil {
+        tx.Rollback()
+        return nil, err
+    }
+    return tx, nil
+}
+
+// planTableRun — делит большую таблицу на части; nil, если таблица идёт целиком.
+// Делятся только таблицы, которые синхронизировались бы чанками по числовому PK.
+func planTableRun(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string, estimate int64) (*tableRun, error) {
+    if cfg.ParallelTableRows <= 0 || estimate < cfg.ParallelTableRows || cfg.Workers < 2 ||
+        cfg.FDWMode || cfg.CaptureMode != "" || cfg.IncrementalMode != "" || inSlice(cfg.ReloadTables, tableName) {
+        return nil, nil
+    }
+    pkCols, numericPK := detectPK(ctx, mainTx, cfg.Schema, tableName)
+    if len(pkCols) != 1 || !numericPK {
+        return nil, nil
+    }
+    pkCol := pkCols[0]
+
+    qMinMax := fmt.Sprintf(`SELECT COALESCE(MIN("%s"),0), COALESCE(MAX("%s"),0) FROM "%s"."%s" WHERE %s`,
+        pkCol, pkCol, cfg.Schema, tableName, filterCond(cfg, tableName))
+    var minID, maxID int64
+    if err := mainTx.QueryRowContext(snapshotContext(ctx), qMinMax).Scan(&minID, &maxID); err != nil {
+        return nil, fmt.Errorf("MIN/MAX %s: %v", tableName, err)
+    }
+    from := minID
+    if cfg.Resume {
+        pos, ok, err := loadResumePoint(ctx, cfg.StandinSchema, standinTable(cfg, tableName))
+        if err != nil {
+            return nil, fmt.Errorf("loadResumePoint(%s): %v", tableName, err)
+        }
+        if ok && pos >= minID {
+            from = pos + 1
+            progressFrom(ctx).setPosition(tableName, minID, maxID, pos)
+        }
+    }
+    n := int64(cfg.Workers * tablePartsPerWorker)
+    if maxID-from+1 < n {
+        return nil, nil
+    }
+
+    run := &tableRun{cfg: cfg, table: tableName, pkCol: pkCol}
+    width := (maxID - from + 1) / n
+    for i := int64(0); i < n; i++ {
+        start := from + i*width
+        end := start + width - 1
+        if i == n-1 {
+            end = maxID
+        }
+        run.parts = append(run.parts, tablePart{start: start, end: end, lastDone: start - 1})
+    }
+    run.remaining = len(run.parts)
+    logFrom(ctx).Info(T("large table split into parts"), "table", tableName, "parts", len(run.parts),
+        "estimated_rows", estimate, "pk_min", from, "pk_max", maxID)
+    return run, nil
+}
+
+// start — подготовка таблицы первой взятой частью; остальные части ждут её окончания
+func (r *tableRun) start(base context.Context, tx *sql.Tx) error {
+    r.startOnce.Do(func() {
+        ctx := withLogAttrs(base, "table", r.table)
+        if r.cfg.TableTimeout > 0 {
+            ctx, r.cancel = context.WithTimeout(ctx, r.cfg.TableTimeout)
+        }
+        r.ctx = withDeleteGuard(ctx, r.cfg, r.table)
+        r.started = time.Now()
+        logFrom(r.ctx).Info(T("table sync started"), "parts", len(r.parts))
+        progressFrom(r.ctx).startTable(r.table)
+
+        if r.bl, r.startErr = beginBulkLoad(r.ctx, r.cfg, r.table); r.startErr != nil {
+            return
+        }
+        // plan == nil: у таблицы нет общих столбцов — частям нечего делать
+        r.plan, r.startErr = prepareChunkPlan(r.ctx, r.cfg, tx, r.table, r.pkCol)
+    })
+    return r.startErr
+}
+
+// syncPart — синхронизирует одну часть таблицы в транзакции воркера tx.
+// Возвращает true, если это была последняя часть и таблица завершена.
+func (r *tableRun) syncPart(base context.Context, tx *sql.Tx, workerID, part int) (bool, error) {
+    err := r.start(base, tx)
+    r.mu.Lock()
+    failed := r.err != nil
+    r.mu.Unlock()
+    // Таблица уже упала в другой части — оставшиеся части не начинаем
+    if err == nil && r.plan != nil && !failed {
+        p := r.parts[part]
+        ctx := withLogAttrs(r.ctx, "worker", workerID, "part", part+1)
+        _, err = syncChunkRange(ctx, r.cfg, tx, r.plan, p.start, p.end, true, func(lastDone int64) {
+            r.mu.Lock()
+            r.parts[part].lastDone = lastDone
+            r.stopped = true
+            r.mu.Unlock()
+        })
+    }
+
+    r.mu.Lock()
+    switch {
+    case failed:
+        // Часть не проходилась: для точки --resume она остаётся непройденной
+    case err == nil:
+        r.parts[part].done = true
+        r.parts[part].lastDone = r.parts[part].end
+    case r.err == nil:
+        r.err = err
+    }
+    r.remaining--
+    last := r.remaining == 0
+    r.mu.Unlock()
+
+    if last {
+        return true, r.finish()
+    }
+    return false, err
+}
+
+// finish — завершение таблицы после всех частей (или после остановки прохода, см. abort)
+func (r *tableRun) finish() error {
+    r.mu.Lock()
+    if r.finished {
+        r.mu.Unlock()
+        return r.err
+    }
+    r.finished = true
+    err := r.err
+    r.mu.Unlock()
+    if r.ctx == nil {
+        // Ни одна часть не начиналась
+        return err
+    }
+    if r.cancel != nil {
+        defer r.cancel()
+    }
+
+    if err == nil {
+        err = purgeOutsideFilter(r.ctx, r.cfg, r.table)
+    }
+    if r.bl != nil {
+        if ferr := r.bl.finish(applyContext(r.ctx), r.cfg.Workers); ferr != nil {
+            if err == nil {
+                err = ferr
+            } else {
+                logFrom(r.ctx).Warn(T("bulk load restore failed"), "table", r.table, "error", ferr)
+            }
+        }
+    }
+    r.saveResumePoint(err)
+    observeSince(metricTableDuration, r.table, r.started)
+    if err != nil {
+        metricErrors.Add("data", 1)
+        reportFrom(r.ctx).tableFailed(r.table, err)
+        return err
+    }
+    progressFrom(r.ctx).finishTable(r.table)
+    logFrom(r.ctx).Info(T("table sync finished"), "duration", time.Since(r.started).Round(time.Millisecond))
+    return nil
+}
+
+// abort — завершение таблицы, часть которой так и осталась в очереди (проход остановлен
+// или воркеры вышли по ошибке): индексы --bulk-load возвращаются, точка --resume сохраняется
+func (r *tableRun) abort() {
+    r.mu.Lock()
+    if r.err == nil {
+        r.err = errShutdown
+    }
+    r.stopped = true
+    r.mu.Unlock()
+    if err := r.finish(); err != nil && !errors.Is(err, errShutdown) && r.ctx != nil {
+        logFrom(r.ctx).Debug(T("table sync failed"), "error", err)
+    }
+}
+
+// saveResumePoint — при остановке запоминает PK, до которого все части пройдены;
+// после успешного прохода с --resume сохранённая точка удаляется
+func (r *tableRun) saveResumePoint(err error) {
+    sSchema, sTable := r.cfg.StandinSchema, standinTable(r.cfg, r.table)
+    r.mu.Lock()
+    stopped := r.stopped
+    lastDone := r.parts[len(r.parts)-1].end
+    for _, p := range r.parts {
+        if !p.done {
+            lastDone = p.lastDone
+            break
+        }
+    }
+    r.mu.Unlock()
+
+    if err == nil {
+        if r.cfg.Resume {
+            if cerr := clearResumePoint(r.ctx, sSchema, sTable); cerr != nil {
+                logFrom(r.ctx).Warn(T("failed to clear resume point"), "error", cerr)
+            }
+        }
+        return
+    }
+    if !stopped {
+        return
+    }
+    if rerr := recordInterruption(applyContext(r.ctx), sSchema, sTable, lastDone); rerr != nil {
+        logFrom(r.ctx).Warn(T("failed to record resume point"), "error", rerr)
+    }
+}
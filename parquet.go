@@ -0,0 +1,459 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "math"
+    "time"
+)
+
+// Запись Parquet без внешних зависимостей — ровно то, что нужно выгрузке (export.go):
+// плоская схема из OPTIONAL-столбцов, кодировка PLAIN, по одной странице данных (v1)
+// на столбец в группе строк, страницы без сжатия или GZIP. Метаданные файла и заголовки
+// страниц пишутся Thrift compact protocol по parquet.thrift. Такие файлы читают
+// pyarrow, Spark, DuckDB, ClickHouse и прочие распространённые читатели.
+
+// parquetRowGroupRows — строк в группе: больше — меньше накладных расходов при чтении, но больше памяти при записи
+const parquetRowGroupRows = 100000
+
+// Физические типы, конвертированные типы и прочие перечисления parquet.thrift
+const (
+    pqBoolean   = 0
+    pqInt32     = 1
+    pqInt64     = 2
+    pqDouble    = 5
+    pqByteArray = 6
+
+    pqConvUTF8            = 0
+    pqConvDate            = 6
+    pqConvTimestampMicros = 10
+    pqConvJSON            = 19
+
+    pqOptional      = 1
+    pqEncodingPlain = 0
+    pqEncodingRLE   = 3
+    pqCodecNone     = 0
+    pqCodecGzip     = 2
+    pqDataPage      = 0
+)
+
+// thriftWriter — Thrift compact protocol: заголовки полей с разницей номеров, zigzag-varint
+type thriftWriter struct {
+    buf  bytes.Buffer
+    last []int16 // номер последнего поля в каждой открытой структуре
+}
+
+func newThriftWriter() *thriftWriter {
+    return &thriftWriter{last: []int16{0}}
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+    w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+    w.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+    last := &w.last[len(w.last)-1]
+    if d := id - *last; d > 0 && d <= 15 {
+        w.buf.WriteByte(byte(d)<<4 | typ)
+    } else {
+        w.buf.WriteByte(typ)
+        w.zigzag(int64(id))
+    }
+    *last = id
+}
+
+func (w *thriftWriter) boolField(id int16, v bool) {
+    if v {
+        w.field(id, 1)
+    } else {
+        w.field(id, 2)
+    }
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+    w.field(id, 5)
+    w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+    w.field(id, 6)
+    w.zigzag(v)
+}
+
+func (w *thriftWriter) stringField(id int16, s string) {
+    w.field(id, 8)
+    w.uvarint(uint64(len(s)))
+    w.buf.WriteString(s)
+}
+
+// listField — заголовок списка из n элементов типа elem (5 — i32, 8 — строка, 12 — структура)
+func (w *thriftWriter) listField(id int16, elem byte, n int) {
+    w.field(id, 9)
+    if n < 15 {
+        w.buf.WriteByte(byte(n)<<4 | elem)
+        return
+    }
+    w.buf.WriteByte(0xF0 | elem)
+    w.uvarint(uint64(n))
+}
+
+// beginStruct — поле-структура; элементы списка структур открываются beginElem
+func (w *thriftWriter) beginStruct(id int16) {
+    w.field(id, 12)
+    w.beginElem()
+}
+
+func (w *thriftWriter) beginElem() {
+    w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+    w.buf.WriteByte(0)
+    w.last = w.last[:len(w.last)-1]
+}
+
+// emptyStruct — структура без полей (варианты union LogicalType и TimeUnit)
+func (w *thriftWriter) emptyStruct(id int16) {
+    w.beginStruct(id)
+    w.endStruct()
+}
+
+// bytes — закрывает верхнюю структуру и возвращает результат
+func (w *thriftWriter) bytes() []byte {
+    w.buf.WriteByte(0)
+    return w.buf.Bytes()
+}
+
+// parquetColumnType — физический тип столбца выгрузки по его виду (см. exportColumn.kind)
+func parquetColumnType(kind string) int32 {
+    switch kind {
+    case "boolean":
+        return pqBoolean
+    case "int32", "date":
+        return pqInt32
+    case "int64", "timestamp", "timestamptz":
+        return pqInt64
+    case "double":
+        return pqDouble
+    }
+    return pqByteArray
+}
+
+// writeSchemaElement — описание столбца в схеме файла: тип, OPTIONAL, аннотации
+func writeSchemaElement(w *thriftWriter, c exportColumn) {
+    w.beginElem()
+    w.i32Field(1, parquetColumnType(c.kind))
+    w.i32Field(3, pqOptional)
+    w.stringField(4, c.name)
+    switch c.kind {
+    case "string":
+        w.i32Field(6, pqConvUTF8)
+        w.beginStruct(10)
+        w.emptyStruct(1) // STRING
+        w.endStruct()
+    case "json":
+        w.i32Field(6, pqConvJSON)
+        w.beginStruct(10)
+        w.emptyStruct(12) // JSON
+        w.endStruct()
+    case "date":
+        w.i32Field(6, pqConvDate)
+        w.beginStruct(10)
+        w.emptyStruct(6) // DATE
+        w.endStruct()
+    case "timestamp", "timestamptz":
+        // timestamp без зоны — «локальное» время: старого конвертированного типа для него нет
+        utc := c.kind == "timestamptz"
+        if utc {
+            w.i32Field(6, pqConvTimestampMicros)
+        }
+        w.beginStruct(10)
+        w.beginStruct(8) // TIMESTAMP
+        w.boolField(1, utc)
+        w.beginStruct(2)
+        w.emptyStruct(2) // MICROS
+        w.endStruct()
+        w.endStruct()
+        w.endStruct()
+    }
+    w.endStruct()
+}
+
+// parquetChunk — записанный столбец группы строк: где лежит и сколько занимает
+type parquetChunk struct {
+    offset       int64
+    uncompressed int64
+    compressed   int64
+}
+
+// parquetRowGroup — группа строк в метаданных файла
+type parquetRowGroup struct {
+    rows   int64
+    chunks []parquetChunk
+}
+
+// parquetWriter — файл Parquet: строки копятся до parquetRowGroupRows и пишутся группой
+type parquetWriter struct {
+    w       io.Writer
+    offset  int64
+    columns []exportColumn
+    gzip    bool
+    rows    [][]interface{}
+    total   int64
+    groups  []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer, columns []exportColumn, compression string) (*parquetWriter, error) {
+    pw := &parquetWriter{w: w, columns: columns, gzip: compression == "gzip"}
+    if err := pw.write([]byte("PAR1")); err != nil {
+        return nil, err
+    }
+    return pw, nil
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+    n, err := pw.w.Write(b)
+    pw.offset += int64(n)
+    return err
+}
+
+func (pw *parquetWriter) writeRow(row []interface{}) error {
+    pw.rows = append(pw.rows, row)
+    if len(pw.rows) >= parquetRowGroupRows {
+        return pw.flush()
+    }
+    return nil
+}
+
+// flush — пишет накопленные строки группой: по странице данных на столбец
+func (pw *parquetWriter) flush() error {
+    if len(pw.rows) == 0 {
+        return nil
+    }
+    rg := parquetRowGroup{rows: int64(len(pw.rows))}
+    for i, c := range pw.columns {
+        body, err := encodeParquetColumn(c, pw.rows, i)
+        if err != nil {
+            return err
+        }
+        data := body
+        if pw.gzip {
+            var zb bytes.Buffer
+            zw := gzip.NewWriter(&zb)
+            zw.Write(body)
+            if err := zw.Close(); err != nil {
+                return err
+            }
+            data = zb.Bytes()
+        }
+
+        h := newThriftWriter()
+        h.i32Field(1, pqDataPage)
+        h.i32Field(2, int32(len(body)))
+        h.i32Field(3, int32(len(data)))
+        h.beginStruct(5)
+        h.i32Field(1, int32(len(pw.rows)))
+        h.i32Field(2, pqEncodingPlain)
+        h.i32Field(3, pqEncodingRLE)
+        h.i32Field(4, pqEncodingRLE)
+        h.endStruct()
+        header := h.bytes()
+
+        chunk := parquetChunk{
+            offset:       pw.offset,
+            uncompressed: int64(len(header) + len(body)),
+            compressed:   int64(len(header) + len(data)),
+        }
+        if err := pw.write(header); err != nil {
+            return err
+        }
+        if err := pw.write(data); err != nil {
+            return err
+        }
+        rg.chunks = append(rg.chunks, chunk)
+    }
+    pw.groups = append(pw.groups, rg)
+    pw.total += rg.rows
+    pw.rows = pw.rows[:0]
+    return nil
+}
+
+// close — дописывает последнюю группу и метаданные файла (FileMetaData)
+func (pw *parquetWriter) close() error {
+    if err := pw.flush(); err != nil {
+        return err
+    }
+    codec := int32(pqCodecNone)
+    if pw.gzip {
+        codec = pqCodecGzip
+    }
+
+    m := newThriftWriter()
+    m.i32Field(1, 1)
+    m.listField(2, 12, len(pw.columns)+1)
+    m.beginElem()
+    m.stringField(4, "schema")
+    m.i32Field(5, int32(len(pw.columns)))
+    m.endStruct()
+    for _, c := range pw.columns {
+        writeSchemaElement(m, c)
+    }
+    m.i64Field(3, pw.total)
+    m.listField(4, 12, len(pw.groups))
+    for _, rg := range pw.groups {
+        m.beginElem()
+        m.listField(1, 12, len(rg.chunks))
+        var size int64
+        for i, ch := range rg.chunks {
+            c := pw.columns[i]
+            m.beginElem()
+            m.i64Field(2, ch.offset)
+            m.beginStruct(3)
+            m.i32Field(1, parquetColumnType(c.kind))
+            m.listField(2, 5, 2)
+            m.zigzag(pqEncodingPlain)
+            m.zigzag(pqEncodingRLE)
+            m.listField(3, 8, 1)
+            m.uvarint(uint64(len(c.name)))
+            m.buf.WriteString(c.name)
+            m.i32Field(4, codec)
+            m.i64Field(5, rg.rows)
+            m.i64Field(6, ch.uncompressed)
+            m.i64Field(7, ch.compressed)
+            m.i64Field(9, ch.offset)
+            m.endStruct()
+            m.endStruct()
+            size += ch.uncompressed
+        }
+        m.i64Field(2, size)
+        m.i64Field(3, rg.rows)
+        m.endStruct()
+    }
+    m.stringField(6, "pgsyncer")
+    footer := m.bytes()
+
+    if err := pw.write(footer); err != nil {
+        return err
+    }
+    if err := pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+        return err
+    }
+    return pw.write([]byte("PAR1"))
+}
+
+// writeParquetInfinity — ±infinity даты или времени: наименьшее или наибольшее значение
+// INT32 (DATE) или INT64 (TIMESTAMP) — настоящие даты так далеко не заходят
+func writeParquetInfinity(values *bytes.Buffer, kind string, negative bool) {
+    if kind == "date" {
+        n := int32(math.MaxInt32)
+        if negative {
+            n = math.MinInt32
+        }
+        values.Write(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+        return
+    }
+    n := int64(math.MaxInt64)
+    if negative {
+        n = math.MinInt64
+    }
+    values.Write(binary.LittleEndian.AppendUint64(nil, uint64(n)))
+}
+
+// encodeParquetColumn — тело страницы столбца col: уровни определения (RLE/bit-packed,
+// ширина 1 бит: 1 — значение есть, 0 — NULL) и значения не-NULL в кодировке PLAIN
+func encodeParquetColumn(c exportColumn, rows [][]interface{}, col int) ([]byte, error) {
+    groups := (len(rows) + 7) / 8
+    levels := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+    bits := make([]byte, groups)
+    var values bytes.Buffer
+    var boolBits []byte
+    nBool := 0
+
+    for r, row := range rows {
+        v := row[col]
+        if v == nil {
+            continue
+        }
+        bits[r/8] |= 1 << (r % 8)
+        var err error
+        switch c.kind {
+        case "boolean":
+            b, ok := v.(bool)
+            if !ok {
+                err = fmt.Errorf("ожидалось boolean, получено %T", v)
+                break
+            }
+            if nBool%8 == 0 {
+                boolBits = append(boolBits, 0)
+            }
+            if b {
+                boolBits[nBool/8] |= 1 << (nBool % 8)
+            }
+            nBool++
+        case "int32":
+            var n int64
+            if n, err = exportInt(v); err == nil {
+                if n < math.MinInt32 || n > math.MaxInt32 {
+                    err = fmt.Errorf("значение %d не помещается в int32", n)
+                }
+                values.Write(binary.LittleEndian.AppendUint32(nil, uint32(int32(n))))
+            }
+        case "int64":
+            var n int64
+            if n, err = exportInt(v); err == nil {
+                values.Write(binary.LittleEndian.AppendUint64(nil, uint64(n)))
+            }
+        case "double":
+            f, ok := v.(float64)
+            if !ok {
+                err = fmt.Errorf("ожидалось double, получено %T", v)
+                break
+            }
+            values.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+        case "date", "timestamp", "timestamptz":
+            if s, ok := v.(string); ok && (s == "infinity" || s == "-infinity") {
+                // pgx отдаёт ±infinity строкой: в Parquet — крайние значения типа (см. schema.json)
+                writeParquetInfinity(&values, c.kind, s == "-infinity")
+                break
+            }
+            t, ok := v.(time.Time)
+            if !ok {
+                err = fmt.Errorf("ожидалось время, получено %T", v)
+                break
+            }
+            switch c.kind {
+            case "date":
+                values.Write(binary.LittleEndian.AppendUint32(nil, uint32(int32(exportDays(t)))))
+            case "timestamp":
+                values.Write(binary.LittleEndian.AppendUint64(nil, uint64(exportLocalTime(t).UnixMicro())))
+            default:
+                values.Write(binary.LittleEndian.AppendUint64(nil, uint64(t.UnixMicro())))
+            }
+        default:
+            var b []byte
+            if x, ok := v.([]byte); ok {
+                b = x
+            } else {
+                b = []byte(exportText(v))
+            }
+            values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(b))))
+            values.Write(b)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("столбец %s: %v", c.name, err)
+        }
+    }
+    levels = append(levels, bits...)
+
+    body := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+    body = append(body, levels...)
+    body = append(body, boolBits...)
+    return append(body, values.Bytes()...), nil
+}
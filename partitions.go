@@ -0,0 +1,250 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sort"
+)
+
+// Секционированные таблицы. listTables возвращает и секционированного родителя
+// (relkind = 'p'), и каждую его секцию, поэтому перед синхронизацией выбирается уровень
+// (--partition-mode):
+//   leaf   — секции синхронизируются как отдельные таблицы, параллельно (по умолчанию);
+//   parent — синхронизируется только корневая таблица: строки читаются через неё,
+//            а в standin маршрутизируются по секциям самим PostgreSQL.
+// Перед синхронизацией данных секции, появившиеся в main, создаются в standin
+// (CREATE TABLE ... PARTITION OF с теми же границами), а таблицы, подключённые в main
+// к родителю, подключаются и в standin (ATTACH PARTITION). Секция standin, которую в main
+// отсоединили (DETACH PARTITION), обрабатывается по --detached-partitions:
+//   detach — отсоединяется и в standin и дальше синхронизируется как обычная таблица;
+//   drop   — удаляется из standin (или уходит в карантин при --clean-mode=quarantine),
+//            а отсоединённая таблица main в этом проходе не синхронизируется.
+
+// partitionInfo — иерархия секционирования таблиц схемы
+type partitionInfo struct {
+    tables      map[string]bool   // все таблицы схемы (relkind r и p)
+    parent      map[string]string // секция -> родитель
+    partitioned map[string]bool   // секционированные таблицы (relkind = 'p')
+    bound       map[string]string // секция -> FOR VALUES ... | DEFAULT
+    keyDef      map[string]string // секционированная таблица -> RANGE (...) | LIST (...) | HASH (...)
+}
+
+// loadPartitions — читает иерархию секционирования схемы из pg_class/pg_inherits
+func loadPartitions(ctx context.Context, db interface {
+    QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}, schema string) (*partitionInfo, error) {
+    q := `
+SELECT c.relname,
+       c.relkind = 'p',
+       COALESCE(p.relname, ''),
+       COALESCE(pg_get_expr(c.relpartbound, c.oid), ''),
+       CASE WHEN c.relkind = 'p' THEN pg_get_partkeydef(c.oid) ELSE '' END
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_inherits i ON c.relispartition AND i.inhrelid = c.oid
+LEFT JOIN pg_class p ON p.oid = i.inhparent AND p.relnamespace = c.relnamespace
+WHERE n.nspname = $1
+  AND c.relkind IN ('r', 'p')
+`
+    rows, err := db.QueryContext(ctx, q, schema)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    pi := &partitionInfo{
+        tables:      make(map[string]bool),
+        parent:      make(map[string]string),
+        partitioned: make(map[string]bool),
+        bound:       make(map[string]string),
+        keyDef:      make(map[string]string),
+    }
+    for rows.Next() {
+        var name, parent, bound, keyDef string
+        var partitioned bool
+        if err := rows.Scan(&name, &partitioned, &parent, &bound, &keyDef); err != nil {
+            return nil, err
+        }
+        pi.tables[name] = true
+        if partitioned {
+            pi.partitioned[name] = true
+            pi.keyDef[name] = keyDef
+        }
+        if parent != "" {
+            pi.parent[name] = parent
+            pi.bound[name] = bound
+        }
+    }
+    return pi, rows.Err()
+}
+
+// root — корневая таблица иерархии (для обычной таблицы — она сама)
+func (pi *partitionInfo) root(table string) string {
+    for pi.parent[table] != "" {
+        table = pi.parent[table]
+    }
+    return table
+}
+
+// leaves — конечные секции таблицы (для обычной таблицы — она сама)
+func (pi *partitionInfo) leaves(table string) []string {
+    if !pi.partitioned[table] {
+        return []string{table}
+    }
+    var out []string
+    for child, parent := range pi.parent {
+        if parent == table {
+            out = append(out, pi.leaves(child)...)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// depth — глубина секции в иерархии (0 — корень или обычная таблица)
+func (pi *partitionInfo) depth(table string) int {
+    d := 0
+    for pi.parent[table] != "" {
+        table = pi.parent[table]
+        d++
+    }
+    return d
+}
+
+// selectLevel — приводит список таблиц к уровню mode: leaf заменяет секционированные таблицы
+// их конечными секциями, parent — секции их корнем. Порядок сохраняется, повторы убираются.
+func (pi *partitionInfo) selectLevel(tables []string, mode string) []string {
+    var out []string
+    seen := make(map[string]bool, len(tables))
+    add := func(t string) {
+        if !seen[t] {
+            seen[t] = true
+            out = append(out, t)
+        }
+    }
+    for _, t := range tables {
+        if mode == "parent" {
+            add(pi.root(t))
+            continue
+        }
+        for _, leaf := range pi.leaves(t) {
+            add(leaf)
+        }
+    }
+    return out
+}
+
+// rollupEstimates — в режиме parent оценка строк корня = сумма оценок его конечных секций
+func (pi *partitionInfo) rollupEstimates(est map[string]int64) map[string]int64 {
+    out := make(map[string]int64, len(est))
+    for t, n := range est {
+        if !pi.partitioned[t] {
+            out[pi.root(t)] += n
+        }
+    }
+    return out
+}
+
+// syncPartitions — создаёт в standin секции, появившиеся в main, и обрабатывает секции,
+// отсоединённые в main. Возвращает таблицы main, которые в этом проходе синхронизировать не нужно.
+func syncPartitions(ctx context.Context, cfg *Config, mainParts *partitionInfo, mainTables []string) (map[string]bool, error) {
+    standinParts, err := loadPartitions(ctx, standinDB, cfg.StandinSchema)
+    if err != nil {
+        return nil, fmt.Errorf("loadPartitions(standinDB): %v", err)
+    }
+    mainSet := make(map[string]bool, len(mainTables))
+    for _, t := range mainTables {
+        mainSet[t] = true
+    }
+
+    // 1) Новые секции: родители раньше детей, чтобы вложенные секции было куда подключать
+    var newParts []string
+    for child, parent := range mainParts.parent {
+        if standinParts.parent[standinTable(cfg, child)] != standinTable(cfg, parent) {
+            newParts = append(newParts, child)
+        }
+    }
+    sort.Slice(newParts, func(i, j int) bool {
+        di, dj := mainParts.depth(newParts[i]), mainParts.depth(newParts[j])
+        if di != dj {
+            return di < dj
+        }
+        return newParts[i] < newParts[j]
+    })
+    for _, child := range newParts {
+        sChild, sParent := standinTable(cfg, child), standinTable(cfg, mainParts.parent[child])
+        if !standinParts.partitioned[sParent] || standinParts.parent[sChild] != "" {
+            // Родителя нет в standin (структуру переносит --sync-schema)
+            // или секция подключена к другому родителю — не трогаем
+            continue
+        }
+        if standinParts.tables[sChild] {
+            // Таблица уже есть в standin отдельно: в main её подключили к родителю
+            q := fmt.Sprintf(`ALTER TABLE "%[1]s"."%[2]s" ATTACH PARTITION "%[1]s"."%[3]s" %[4]s`,
+                cfg.StandinSchema, sParent, sChild, mainParts.bound[child])
+            if _, err := standinDB.ExecContext(ctx, q); err != nil {
+                return nil, fmt.Errorf("подключение секции %s в standin: %v", sChild, err)
+            }
+            standinParts.parent[sChild] = sParent
+            logFrom(ctx).Info(T("partition attached on standin"), "table", sChild, "parent", sParent, "bound", mainParts.bound[child])
+            continue
+        }
+        q := fmt.Sprintf(`CREATE TABLE "%[1]s"."%[2]s" PARTITION OF "%[1]s"."%[3]s" %[4]s`,
+            cfg.StandinSchema, sChild, sParent, mainParts.bound[child])
+        if mainParts.partitioned[child] {
+            q += " PARTITION BY " + mainParts.keyDef[child]
+            standinParts.partitioned[sChild] = true
+        }
+        if _, err := standinDB.ExecContext(ctx, q); err != nil {
+            return nil, fmt.Errorf("создание секции %s в standin: %v", sChild, err)
+        }
+        standinParts.tables[sChild] = true
+        standinParts.parent[sChild] = sParent
+        logFrom(ctx).Info(T("partition created on standin"), "table", sChild, "parent", sParent, "bound", mainParts.bound[child])
+    }
+
+    // 2) Секции standin, которые в main существуют, но больше не подключены к родителю
+    skip := make(map[string]bool)
+    var detached []string
+    for sChild, sParent := range standinParts.parent {
+        child := mainTableFor(cfg, sChild)
+        if !mainSet[child] || mainParts.parent[child] == mainTableFor(cfg, sParent) {
+            continue
+        }
+        detached = append(detached, sChild)
+    }
+    sort.Strings(detached)
+    if cfg.DetachedPartitions == "drop" {
+        if err := checkDropAllowed(cfg, detached); err != nil {
+            return nil, err
+        }
+    }
+    for _, sChild := range detached {
+        sParent := standinParts.parent[sChild]
+        q := fmt.Sprintf(`ALTER TABLE "%[1]s"."%[2]s" DETACH PARTITION "%[1]s"."%[3]s"`, cfg.StandinSchema, sParent, sChild)
+        if _, err := standinDB.ExecContext(ctx, q); err != nil {
+            return nil, fmt.Errorf("отсоединение секции %s в standin: %v", sChild, err)
+        }
+        logFrom(ctx).Info(T("partition detached on standin"), "table", sChild, "parent", sParent)
+        if cfg.DetachedPartitions != "drop" {
+            continue
+        }
+
+        skip[mainTableFor(cfg, sChild)] = true
+        reportDependents(ctx, cfg.StandinSchema, sChild)
+        if cfg.CleanMode == "quarantine" {
+            name, err := quarantineTable(ctx, cfg.StandinSchema, sChild)
+            if err != nil {
+                return nil, fmt.Errorf("карантин секции %s: %v", sChild, err)
+            }
+            logFrom(ctx).Info(T("extra table moved to quarantine"), "table", sChild, "trash_table", trashSchema+"."+name)
+            continue
+        }
+        if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`DROP TABLE "%s"."%s" CASCADE`, cfg.StandinSchema, sChild)); err != nil {
+            return nil, fmt.Errorf("удаление секции %s в standin: %v", sChild, err)
+        }
+        logFrom(ctx).Info(T("detached partition dropped on standin"), "table", sChild)
+    }
+    return skip, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Прогресс синхронизации данных. Перед запуском воркеров размер каждой таблицы
+// оценивается по pg_class (reltuples/relpages), дальше:
+//   - чанковая синхронизация двигает долю готовности по границам чанков (PK-диапазон);
+//   - остальные режимы считают прочитанные строки относительно оценки.
+// Скорость (строк/с) и ETA считаются по фактически прочитанным строкам.
+// Вывод: на терминале — перерисовываемый блок внизу stderr, иначе — периодические строки лога.
+
+// tableProgress — состояние одной таблицы
+type tableProgress struct {
+    estRows  int64   // оценка числа строк по pg_class
+    rows     int64   // прочитано строк
+    fraction float64 // доля готовности по PK-диапазону (-1 — неизвестна, считаем по rows)
+    started  time.Time
+    finished bool
+}
+
+// percent — готовность таблицы в процентах
+func (p *tableProgress) percent() float64 {
+    switch {
+    case p.finished:
+        return 100
+    case p.fraction >= 0:
+        return p.fraction * 100
+    case p.estRows > 0:
+        // Оценка бывает устаревшей: до конца таблицы не показываем 100%
+        return minFloat(float64(p.rows)/float64(p.estRows)*100, 99)
+    }
+    return 0
+}
+
+// progressTracker — прогресс одного прохода SyncData
+type progressTracker struct {
+    mu      sync.Mutex
+    started time.Time
+    tables  map[string]*tableProgress
+    order   []string
+}
+
+type progressKey struct{}
+
+// withProgress — кладёт трекер в контекст (у каждого прохода и задания daemon — свой)
+func withProgress(ctx context.Context, p *progressTracker) context.Context {
+    return context.WithValue(ctx, progressKey{}, p)
+}
+
+// progressFrom — трекер из контекста; nil, если прогресс не ведётся (методы nil-безопасны)
+func progressFrom(ctx context.Context) *progressTracker {
+    p, _ := ctx.Value(progressKey{}).(*progressTracker)
+    return p
+}
+
+func newProgressTracker(tables []string, estimates map[string]int64) *progressTracker {
+    p := &progressTracker{
+        started: time.Now(),
+        tables:  make(map[string]*tableProgress, len(tables)),
+        order:   tables,
+    }
+    for _, t := range tables {
+        p.tables[t] = &tableProgress{estRows: estimates[t], fraction: -1}
+    }
+    return p
+}
+
+func (p *progressTracker) get(table string) *tableProgress {
+    t, ok := p.tables[table]
+    if !ok {
+        t = &tableProgress{fraction: -1}
+        p.tables[table] = t
+        p.order = append(p.order, table)
+    }
+    if t.started.IsZero() {
+        t.started = time.Now()
+    }
+    return t
+}
+
+// startTable — таблица взята воркером
+func (p *progressTracker) startTable(table string) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    p.get(table)
+    p.mu.Unlock()
+}
+
+// setPosition — чанковая синхронизация дошла до pos в диапазоне [min..max]
+func (p *progressTracker) setPosition(table string, min, max, pos int64) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    t := p.get(table)
+    if max <= min {
+        t.fraction = 1
+        return
+    }
+    t.fraction = minFloat(float64(pos-min+1)/float64(max-min+1), 1)
+}
+
+// addFraction — пройдена ещё доля f диапазона таблицы (части таблицы идут параллельно)
+func (p *progressTracker) addFraction(table string, f float64) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    t := p.get(table)
+    if t.fraction < 0 {
+        t.fraction = 0
+    }
+    t.fraction = minFloat(t.fraction+f, 1)
+}
+
+// addRows — прочитано ещё n строк таблицы
+func (p *progressTracker) addRows(table string, n int) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    p.get(table).rows += int64(n)
+    p.mu.Unlock()
+}
+
+// finishTable — таблица синхронизирована
+func (p *progressTracker) finishTable(table string) {
+    if p == nil {
+        return
+    }
+    p.mu.Lock()
+    p.get(table).finished = true
+    p.mu.Unlock()
+}
+
+// progressLine — снимок прогресса одной таблицы (или всего прохода при table == "")
+type progressLine struct {
+    table   string
+    percent float64
+    rows    int64
+    estRows int64
+    rate    float64 // строк в секунду
+    eta     time.Duration
+}
+
+// snapshot — итог по проходу и строки по таблицам в работе
+func (p *progressTracker) snapshot() (total progressLine, active []progressLine) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    var estSum, doneEst float64
+    for _, name := range p.order {
+        t := p.tables[name]
+        est := float64(t.estRows)
+        if est < 1 {
+            est = 1
+        }
+        estSum += est
+        doneEst += est * t.percent() / 100
+        total.rows += t.rows
+        total.estRows += t.estRows
+
+        if t.started.IsZero() || t.finished {
+            continue
+        }
+        line := progressLine{table: name, percent: t.percent(), rows: t.rows, estRows: t.estRows}
+        line.rate = rate(t.rows, time.Since(t.started))
+        line.eta = eta(line.percent, time.Since(t.started))
+        active = append(active, line)
+    }
+    sort.Slice(active, func(i, j int) bool { return active[i].table < active[j].table })
+
+    if estSum > 0 {
+        total.percent = doneEst / estSum * 100
+    }
+    total.rate = rate(total.rows, time.Since(p.started))
+    total.eta = eta(total.percent, time.Since(p.started))
+    return total, active
+}
+
+func rate(rows int64, elapsed time.Duration) float64 {
+    if elapsed <= 0 {
+        return 0
+    }
+    return float64(rows) / elapsed.Seconds()
+}
+
+// eta — оставшееся время при сохранении текущего темпа; 0 — пока не оценить
+func eta(percent float64, elapsed time.Duration) time.Duration {
+    if percent <= 0 || percent >= 100 {
+        return 0
+    }
+    return time.Duration(float64(elapsed) * (100 - percent) / percent).Round(time.Second)
+}
+
+func minFloat(a, b float64) float64 {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+// estimateTableRows — оценка числа строк по pg_class, как у планировщика:
+// плотность reltuples/relpages, умноженная на текущее число страниц.
+// Для ни разу не анализированных таблиц (reltuples = -1 или 0) оценка 0.
+func estimateTableRows(ctx context.Context, mainTx *sql.Tx, schema string) (map[string]int64, error) {
+    q := `
+SELECT c.relname,
+       CASE WHEN c.relpages > 0 AND c.reltuples > 0
+            THEN c.reltuples / c.relpages * (pg_relation_size(c.oid) / current_setting('block_size')::int)
+            ELSE greatest(c.reltuples, 0)
+       END::bigint
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND c.relkind IN ('r', 'p')
+`
+    rows, err := mainTx.QueryContext(snapshotContext(ctx), q, schema)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    est := make(map[string]int64)
+    for rows.Next() {
+        var name string
+        var n int64
+        if err := rows.Scan(&name, &n); err != nil {
+            return nil, err
+        }
+        est[name] = n
+    }
+    return est, rows.Err()
+}
+
+// runProgressReporter — выводит прогресс до отмены ctx.
+// mode: tty — блок внизу терминала раз в секунду, log — строка лога раз в interval.
+func runProgressReporter(ctx context.Context, p *progressTracker, mode string, interval time.Duration) {
+    if mode == "tty" {
+        interval = time.Second
+        defer termOut.setBlock(p, nil)
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        total, active := p.snapshot()
+        if mode == "tty" {
+            termOut.setBlock(p, renderProgress(total, active))
+            continue
+        }
+        for _, l := range active {
+            logFrom(ctx).Info(T("table progress"), "table", l.table, "percent", round1(l.percent),
+                "rows", l.rows, "est_rows", l.estRows, "rows_per_sec", int64(l.rate), "eta", l.eta)
+        }
+        logFrom(ctx).Info(T("overall progress"), "percent", round1(total.percent),
+            "rows", total.rows, "est_rows", total.estRows, "rows_per_sec", int64(total.rate), "eta", total.eta)
+    }
+}
+
+func round1(v float64) float64 {
+    return float64(int64(v*10)) / 10
+}
+
+// renderProgress — строки блока прогресса для терминала
+func renderProgress(total progressLine, active []progressLine) []string {
+    lines := make([]string, 0, len(active)+1)
+    for _, l := range active {
+        lines = append(lines, formatProgressLine(l.table, l))
+    }
+    return append(lines, formatProgressLine("TOTAL", total))
+}
+
+func formatProgressLine(name string, l progressLine) string {
+    const width = 20
+    filled := int(l.percent / 100 * width)
+    if filled > width {
+        filled = width
+    }
+    bar := strings.Repeat("#", filled) + strings.Repeat(".", width-filled)
+    etaStr := "?"
+    if l.eta > 0 {
+        etaStr = l.eta.String()
+    }
+    return fmt.Sprintf("%-30.30s [%s] %5.1f%%  %d/~%d rows  %.0f rows/s  ETA %s",
+        name, bar, l.percent, l.rows, l.estRows, l.rate, etaStr)
+}
+
+// progressMode — фактический режим вывода для --progress (auto выбирает по stderr)
+func progressMode(cfg *Config) string {
+    if cfg.Progress != "auto" {
+        return cfg.Progress
+    }
+    if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+        return "tty"
+    }
+    return "log"
+}
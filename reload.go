@@ -0,0 +1,646 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+// Перезаливка таблиц целиком (--reload-tables). Для средних справочников полное
+// копирование дешевле диффа: строки из снимка mainTx пишутся в новую UNLOGGED-таблицу
+// standin <таблица>__pgsyncer_reload (LIKE ... INCLUDING ALL EXCLUDING INDEXES),
+// затем она переводится в LOGGED и на ней строятся индексы. Подмена — одна короткая
+// транзакция под ACCESS EXCLUSIVE: прежняя таблица удаляется, новая получает её имя,
+// индексы и ограничения — прежние имена, зависимые представления, внешние ключи
+// (входящие и исходящие), триггеры, права, комментарий и владелец создаются заново.
+// Читатели standin видят либо прежнюю, либо новую версию таблицы, но не наполовину
+// залитую. Внешние ключи создаются NOT VALID и проверяются уже после подмены.
+// Если от таблицы зависит что-то ещё (функции с её типом строки, правила других таблиц),
+// перезаливка отказывается работать, а не удаляет такие объекты через CASCADE.
+
+const reloadSuffix = "__pgsyncer_reload"
+
+// reloadCursorSeq — номер курсора чтения main: имя курсора не должно повториться в транзакции воркера
+var reloadCursorSeq atomic.Int64
+
+// reloadIndex — индекс таблицы standin и ограничение, которое на нём держится
+type reloadIndex struct {
+    name       string
+    def        string // pg_get_indexdef
+    conName    string // ограничение PRIMARY KEY / UNIQUE / EXCLUDE на этом индексе
+    conType    string // p | u | x | ""
+    conDef     string // pg_get_constraintdef (для EXCLUDE индекс создаёт само ограничение)
+    deferrable bool
+    deferred   bool
+    clustered  bool
+}
+
+// reloadFK — внешний ключ, ссылающийся на таблицу или из неё
+type reloadFK struct {
+    table     string // таблица, которой принадлежит ограничение ("схема"."таблица")
+    name      string
+    def       string
+    validated bool
+}
+
+// reloadView — зависимое представление (обычное или материализованное)
+type reloadView struct {
+    regclass string // имя в виде ::regclass::text — так его называет cascadeDependents
+    name     string // "схема"."имя"
+    kind     string // v | m
+    def      string
+    options  string
+    extras   []string // индексы, триггеры, права, комментарий, владелец
+}
+
+// reloadSequence — последовательность столбца: identity или OWNED BY (serial)
+type reloadSequence struct {
+    column   string
+    identity bool
+    seq      string // ::regclass::text
+    relname  string
+}
+
+// reloadMeta — всё, что нужно пересоздать вокруг новой таблицы при подмене
+type reloadMeta struct {
+    options   string
+    indexes   []reloadIndex
+    fks       []reloadFK
+    views     []reloadView
+    sequences []reloadSequence
+    extras    []string // триггеры, права, комментарий, владелец самой таблицы
+}
+
+// reloadName — имя служебного объекта перезаливки; имя таблицы укорачивается под лимит 63 байта
+func reloadName(table, suffix string) string {
+    if max := 63 - len(suffix); len(table) > max {
+        table = table[:max]
+    }
+    return table + suffix
+}
+
+// reloadable — можно ли перезалить таблицу standin целиком. Секционированные таблицы и секции
+// перезаливка не обрабатывает: для них остаётся обычная синхронизация.
+func reloadable(ctx context.Context, cfg *Config, tableName string) (bool, error) {
+    var kind string
+    var partition bool
+    err := standinDB.QueryRowContext(ctx, `
+SELECT c.relkind::text, c.relispartition
+FROM pg_class c
+WHERE c.oid = to_regclass($1::text)`,
+        fmt.Sprintf(`"%s"."%s"`, cfg.StandinSchema, standinTable(cfg, tableName))).Scan(&kind, &partition)
+    if err == sql.ErrNoRows {
+        return false, fmt.Errorf("таблицы %s нет в standin — перезаливать нечего (структуру переносит --sync-schema)",
+            standinTable(cfg, tableName))
+    }
+    if err != nil {
+        return false, err
+    }
+    if kind != "r" || partition {
+        logFrom(ctx).Warn(T("reload does not support partitioned tables, using regular sync"))
+        return false, nil
+    }
+    return true, nil
+}
+
+// syncTableReload — перезаливка таблицы: копия в staging-таблицу и атомарная подмена
+func syncTableReload(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    sSchema, sTable := cfg.StandinSchema, standinTable(cfg, tableName)
+    live := fmt.Sprintf(`"%s"."%s"`, sSchema, sTable)
+    stagingName := reloadName(sTable, reloadSuffix)
+    staging := fmt.Sprintf(`"%s"."%s"`, sSchema, stagingName)
+    logFrom(ctx).Info(T("reloading table via staging copy"), "staging", stagingName)
+
+    columns, types, err := syncColumns(ctx, cfg, mainTx, tableName)
+    if err != nil {
+        return fmt.Errorf("[syncTableReload] syncColumns(%s): %v", tableName, err)
+    }
+    pkCols, _ := detectPK(ctx, mainTx, cfg.Schema, tableName)
+    tr, err := transformFor(cfg, tableName, columns, types, pkCols)
+    if err != nil {
+        return err
+    }
+    meta, err := loadReloadMeta(ctx, live)
+    if err != nil {
+        return fmt.Errorf("[syncTableReload] зависимости %s: %v", sTable, err)
+    }
+    if err := checkReloadDependents(ctx, sSchema, sTable, meta); err != nil {
+        return err
+    }
+
+    // Остатки прерванной перезаливки
+    if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, staging)); err != nil {
+        return fmt.Errorf("удаление старой staging-таблицы %s: %v", stagingName, err)
+    }
+    create := fmt.Sprintf(`CREATE UNLOGGED TABLE %s (LIKE %s INCLUDING ALL EXCLUDING INDEXES)`, staging, live)
+    if meta.options != "" {
+        create += " WITH (" + meta.options + ")"
+    }
+    if _, err := standinDB.ExecContext(ctx, create); err != nil {
+        return fmt.Errorf("создание staging-таблицы %s: %v", stagingName, err)
+    }
+    swapped := false
+    defer func() {
+        if !swapped {
+            if _, err := standinDB.ExecContext(applyContext(ctx), fmt.Sprintf(`DROP TABLE IF EXISTS %s`, staging)); err != nil {
+                logFrom(ctx).Warn(T("failed to drop staging table"), "staging", stagingName, "error", err)
+            }
+        }
+    }()
+
+    loaded, err := loadReloadStaging(ctx, cfg, mainTx, tableName, stagingName, columns, tr)
+    if err != nil {
+        return fmt.Errorf("[syncTableReload] копирование %s: %w", tableName, err)
+    }
+
+    // Прежние строки standin, которых нет среди залитых, пропадут при подмене
+    total, err := standinRowCount(ctx, sSchema, sTable)
+    if err != nil {
+        return fmt.Errorf("подсчёт строк standin %s: %v", sTable, err)
+    }
+    if total > loaded {
+        if err := checkDeleteAllowed(ctx, int(total-loaded)); err != nil {
+            return err
+        }
+    }
+
+    if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s SET LOGGED`, staging)); err != nil {
+        return fmt.Errorf("ALTER TABLE %s SET LOGGED: %v", stagingName, err)
+    }
+    for i, idx := range meta.indexes {
+        if idx.conType == "x" {
+            continue
+        }
+        if err := checkStop(ctx); err != nil {
+            return err
+        }
+        q, err := stagingIndexDef(idx.def, staging, reloadName(sTable, fmt.Sprintf("%s_i%d", reloadSuffix, i)))
+        if err != nil {
+            return err
+        }
+        if _, err := standinDB.ExecContext(ctx, q); err != nil {
+            return fmt.Errorf("индекс %s на staging-таблице: %v", idx.name, err)
+        }
+    }
+    if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`ANALYZE %s`, staging)); err != nil {
+        return fmt.Errorf("ANALYZE %s: %v", stagingName, err)
+    }
+    if err := checkStop(ctx); err != nil {
+        return err
+    }
+
+    started := time.Now()
+    if err := swapReloadTable(applyContext(ctx), cfg, sSchema, sTable, stagingName, meta); err != nil {
+        return fmt.Errorf("[syncTableReload] подмена %s: %v", sTable, err)
+    }
+    swapped = true
+    logFrom(ctx).Info(T("table swapped"), "rows", loaded, "replaced_rows", total, "swap_duration", time.Since(started).Round(time.Millisecond))
+
+    // Внешние ключи созданы NOT VALID: проверка не блокирует чтение и запись таблиц
+    for _, fk := range meta.fks {
+        if !fk.validated {
+            continue
+        }
+        q := fmt.Sprintf(`ALTER TABLE %s VALIDATE CONSTRAINT "%s"`, fk.table, fk.name)
+        if _, err := standinDB.ExecContext(ctx, q); err != nil {
+            logFrom(ctx).Warn(T("foreign key validation failed after reload"), "constraint", fk.name, "table", fk.table, "error", err)
+        }
+    }
+    metricRowsInserted.Add(tableName, float64(loaded))
+    return nil
+}
+
+// loadReloadStaging — копирует строки main в staging-таблицу. Чтение идёт курсором:
+// в памяти держится только один пакет строк.
+func loadReloadStaging(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName, stagingName string,
+    columns []string, tr *tableTransform) (int64, error) {
+    cursor := fmt.Sprintf("pgsyncer_reload_%d", reloadCursorSeq.Add(1))
+    q := fmt.Sprintf(`DECLARE %s NO SCROLL CURSOR FOR SELECT %s FROM "%s"."%s" WHERE %s`,
+        cursor, selectColumns("", columns, castsFor(cfg, tableName)), cfg.Schema, tableName, filterCond(cfg, tableName))
+    if _, err := mainTx.ExecContext(snapshotContext(ctx), q); err != nil {
+        return 0, err
+    }
+    defer mainTx.ExecContext(snapshotContext(ctx), "CLOSE "+cursor)
+
+    sSchema := cfg.StandinSchema
+    insert := fmt.Sprintf(`INSERT INTO "%s"."%s" (%s) %s VALUES `,
+        sSchema, stagingName, quoteColumns(columns), overridingClause(sSchema, standinTable(cfg, tableName), columns))
+    batchSize := upsertBatchSize(len(columns), cfg.ChunkSize)
+    var loaded int64
+    for {
+        if err := checkStop(ctx); err != nil {
+            return loaded, err
+        }
+        release, err := throttleFrom(ctx).acquire(ctx)
+        if err != nil {
+            return loaded, err
+        }
+        batch, err := fetchCursorBatch(ctx, mainTx, cursor, batchSize, len(columns), tr)
+        release()
+        if err != nil {
+            return loaded, err
+        }
+        if len(batch) == 0 {
+            return loaded, nil
+        }
+        q := insert + makePlaceholderMatrix(len(batch), len(columns))
+        if _, err := standinDB.ExecContext(ctx, q, flatten(batch)...); err != nil {
+            return loaded, err
+        }
+        loaded += int64(len(batch))
+        metricChunks.Add(tableName, 1)
+        progressFrom(ctx).addRows(tableName, len(batch))
+        var batchBytes int64
+        for _, vals := range batch {
+            batchBytes += rowBytes(vals)
+        }
+        if err := throttleFrom(ctx).consume(ctx, len(batch), batchBytes); err != nil {
+            return loaded, err
+        }
+    }
+}
+
+// fetchCursorBatch — следующий пакет строк курсора с применёнными --transform
+func fetchCursorBatch(ctx context.Context, tx *sql.Tx, cursor string, n, numCols int, tr *tableTransform) ([][]interface{}, error) {
+    rows, err := tx.QueryContext(snapshotContext(ctx), fmt.Sprintf("FETCH %d FROM %s", n, cursor))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var batch [][]interface{}
+    for rows.Next() {
+        vals := make([]interface{}, numCols)
+        ptrs := make([]interface{}, numCols)
+        for i := range vals {
+            ptrs[i] = &vals[i]
+        }
+        if err := rows.Scan(ptrs...); err != nil {
+            return nil, err
+        }
+        tr.apply(vals)
+        batch = append(batch, vals)
+    }
+    return batch, rows.Err()
+}
+
+// stagingIndexDef — определение индекса таблицы standin, перенесённое на staging-таблицу под именем name
+func stagingIndexDef(def, staging, name string) (string, error) {
+    i := strings.Index(def, " USING ")
+    if i < 0 {
+        return "", fmt.Errorf("не удалось разобрать определение индекса: %s", def)
+    }
+    unique := ""
+    if strings.HasPrefix(def, "CREATE UNIQUE INDEX") {
+        unique = "UNIQUE "
+    }
+    return fmt.Sprintf(`CREATE %sINDEX "%s" ON %s%s`, unique, name, staging, def[i:]), nil
+}
+
+// loadReloadMeta — индексы, внешние ключи, последовательности, зависимые представления
+// и прочие свойства таблицы standin, которые надо восстановить после подмены
+func loadReloadMeta(ctx context.Context, live string) (*reloadMeta, error) {
+    meta := &reloadMeta{}
+    err := standinDB.QueryRowContext(ctx,
+        `SELECT COALESCE(array_to_string(reloptions, ', '), '') FROM pg_class WHERE oid = to_regclass($1::text)`,
+        live).Scan(&meta.options)
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := standinDB.QueryContext(ctx, `
+SELECT ic.relname, pg_get_indexdef(i.indexrelid),
+       COALESCE(con.conname, ''), COALESCE(con.contype::text, ''),
+       COALESCE(pg_get_constraintdef(con.oid), ''),
+       COALESCE(con.condeferrable, false), COALESCE(con.condeferred, false),
+       i.indisclustered
+FROM pg_index i
+JOIN pg_class ic ON ic.oid = i.indexrelid
+LEFT JOIN pg_constraint con ON con.conindid = i.indexrelid AND con.conrelid = i.indrelid
+                            AND con.contype IN ('p', 'u', 'x')
+WHERE i.indrelid = to_regclass($1::text)
+ORDER BY ic.relname`, live)
+    if err != nil {
+        return nil, err
+    }
+    for rows.Next() {
+        var idx reloadIndex
+        if err := rows.Scan(&idx.name, &idx.def, &idx.conName, &idx.conType, &idx.conDef,
+            &idx.deferrable, &idx.deferred, &idx.clustered); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        meta.indexes = append(meta.indexes, idx)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    // Внешние ключи из таблицы и на неё; ссылки таблицы на саму себя попадают сюда же
+    rows, err = standinDB.QueryContext(ctx, `
+SELECT format('%I.%I', n.nspname, c.relname), con.conname, pg_get_constraintdef(con.oid), con.convalidated
+FROM pg_constraint con
+JOIN pg_class c ON c.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE con.contype = 'f'
+  AND con.conparentid = 0
+  AND (con.conrelid = to_regclass($1::text) OR con.confrelid = to_regclass($1::text))
+ORDER BY 1, 2`, live)
+    if err != nil {
+        return nil, err
+    }
+    for rows.Next() {
+        var fk reloadFK
+        if err := rows.Scan(&fk.table, &fk.name, &fk.def, &fk.validated); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        fk.def = strings.TrimSuffix(fk.def, " NOT VALID")
+        meta.fks = append(meta.fks, fk)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    rows, err = standinDB.QueryContext(ctx, `
+SELECT a.attname, a.attidentity <> '', s.oid::regclass::text, s.relname
+FROM pg_attribute a
+JOIN pg_depend d ON d.refclassid = 'pg_class'::regclass AND d.refobjid = a.attrelid AND d.refobjsubid = a.attnum
+                AND d.classid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+JOIN pg_class s ON s.oid = d.objid AND s.relkind = 'S'
+WHERE a.attrelid = to_regclass($1::text)
+  AND a.attnum > 0
+  AND NOT a.attisdropped
+ORDER BY a.attnum`, live)
+    if err != nil {
+        return nil, err
+    }
+    for rows.Next() {
+        var s reloadSequence
+        if err := rows.Scan(&s.column, &s.identity, &s.seq, &s.relname); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        meta.sequences = append(meta.sequences, s)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    // Представления, в том числе построенные на других представлениях; родители раньше детей
+    rows, err = standinDB.QueryContext(ctx, `
+WITH RECURSIVE v(oid, depth) AS (
+    SELECT r.ev_class, 1
+    FROM pg_depend d
+    JOIN pg_rewrite r ON r.oid = d.objid
+    WHERE d.classid = 'pg_rewrite'::regclass
+      AND d.refclassid = 'pg_class'::regclass
+      AND d.refobjid = to_regclass($1::text)
+      AND r.ev_class <> d.refobjid
+  UNION
+    SELECT r.ev_class, v.depth + 1
+    FROM v
+    JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass
+                    AND d.refclassid = 'pg_class'::regclass
+                    AND d.refobjid = v.oid
+    JOIN pg_rewrite r ON r.oid = d.objid
+    WHERE r.ev_class <> v.oid
+)
+SELECT c.oid::regclass::text, format('%I.%I', n.nspname, c.relname), c.relkind::text,
+       pg_get_viewdef(c.oid), COALESCE(array_to_string(c.reloptions, ', '), '')
+FROM (SELECT oid, max(depth) AS depth FROM v GROUP BY oid) v
+JOIN pg_class c ON c.oid = v.oid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('v', 'm')
+ORDER BY v.depth, 2`, live)
+    if err != nil {
+        return nil, err
+    }
+    for rows.Next() {
+        var v reloadView
+        if err := rows.Scan(&v.regclass, &v.name, &v.kind, &v.def, &v.options); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        v.def = strings.TrimSuffix(strings.TrimSpace(v.def), ";")
+        meta.views = append(meta.views, v)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    if meta.extras, err = relationExtras(ctx, live, "TABLE"); err != nil {
+        return nil, err
+    }
+    for i, v := range meta.views {
+        kind := "VIEW"
+        if v.kind == "m" {
+            kind = "MATERIALIZED VIEW"
+        }
+        if meta.views[i].extras, err = relationExtras(ctx, v.name, kind); err != nil {
+            return nil, err
+        }
+    }
+    return meta, nil
+}
+
+// relationExtras — команды, восстанавливающие свойства отношения после пересоздания:
+// индексы материализованного представления, триггеры, комментарий, права и владельца.
+// Владелец меняется последним: права до этого выдаёт текущий пользователь.
+func relationExtras(ctx context.Context, rel, kind string) ([]string, error) {
+    q := `
+SELECT stmt FROM (
+    SELECT 1 AS ord, pg_get_indexdef(i.indexrelid) AS stmt
+    FROM pg_index i
+    WHERE i.indrelid = to_regclass($1::text) AND $2::text = 'MATERIALIZED VIEW'
+  UNION ALL
+    SELECT 2, pg_get_triggerdef(t.oid)
+    FROM pg_trigger t
+    WHERE t.tgrelid = to_regclass($1::text) AND NOT t.tgisinternal
+  UNION ALL
+    SELECT 3, format('COMMENT ON %s %s IS %L', $2::text, $1::text, obj_description(c.oid, 'pg_class'))
+    FROM pg_class c
+    WHERE c.oid = to_regclass($1::text) AND obj_description(c.oid, 'pg_class') IS NOT NULL
+  UNION ALL
+    SELECT 4, format('GRANT %s ON %s TO %s%s', a.privilege_type, $1::text,
+                     CASE WHEN a.grantee = 0 THEN 'PUBLIC' ELSE quote_ident(pg_get_userbyid(a.grantee)) END,
+                     CASE WHEN a.is_grantable THEN ' WITH GRANT OPTION' ELSE '' END)
+    FROM pg_class c, aclexplode(c.relacl) a
+    WHERE c.oid = to_regclass($1::text) AND a.grantee <> c.relowner
+  UNION ALL
+    SELECT 5, format('ALTER %s %s OWNER TO %I', $2::text, $1::text, pg_get_userbyid(c.relowner))
+    FROM pg_class c
+    WHERE c.oid = to_regclass($1::text) AND pg_get_userbyid(c.relowner) <> current_user
+) s
+ORDER BY ord`
+    rows, err := standinDB.QueryContext(ctx, q, rel, kind)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []string
+    for rows.Next() {
+        var s string
+        if err := rows.Scan(&s); err != nil {
+            return nil, err
+        }
+        out = append(out, s)
+    }
+    return out, rows.Err()
+}
+
+// checkReloadDependents — DROP ... CASCADE прежней таблицы не должен удалить ничего,
+// кроме представлений и внешних ключей, которые подмена создаёт заново
+func checkReloadDependents(ctx context.Context, schema, table string, meta *reloadMeta) error {
+    deps, err := cascadeDependents(ctx, schema, table)
+    if err != nil {
+        return fmt.Errorf("зависимые объекты %s: %v", table, err)
+    }
+    views := make(map[string]bool, len(meta.views))
+    for _, v := range meta.views {
+        views["view "+v.regclass] = true
+    }
+    for _, d := range deps {
+        if views[d] || (strings.HasPrefix(d, "constraint ") && strings.Contains(d, " on table ")) {
+            continue
+        }
+        return fmt.Errorf("перезаливка %s удалила бы зависимый объект standin «%s», который pgsyncer не умеет "+
+            "пересоздать; уберите таблицу из --reload-tables", table, d)
+    }
+    return nil
+}
+
+// swapReloadTable — подмена таблицы standin залитой staging-таблицей в одной транзакции
+func swapReloadTable(ctx context.Context, cfg *Config, schema, table, stagingName string, meta *reloadMeta) error {
+    live := fmt.Sprintf(`"%s"."%s"`, schema, table)
+    staging := fmt.Sprintf(`"%s"."%s"`, schema, stagingName)
+
+    tx, err := standinDB.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    exec := func(q string, args ...interface{}) error {
+        if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+            return fmt.Errorf("%s: %v", q, err)
+        }
+        return nil
+    }
+    if cfg.ReloadLockTimeout > 0 {
+        if err := exec(fmt.Sprintf(`SET LOCAL lock_timeout = %d`, cfg.ReloadLockTimeout.Milliseconds())); err != nil {
+            return err
+        }
+    }
+    if err := exec(fmt.Sprintf(`LOCK TABLE %s IN ACCESS EXCLUSIVE MODE`, live)); err != nil {
+        return err
+    }
+
+    // Последовательности прежней таблицы: identity продолжает счёт, serial переходит к новой таблице
+    for _, s := range meta.sequences {
+        if s.identity {
+            q := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence($1, $2), last_value, is_called) FROM %s`, s.seq)
+            if err := exec(q, staging, s.column); err != nil {
+                return err
+            }
+            continue
+        }
+        if err := exec(fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY %s."%s"`, s.seq, staging, s.column)); err != nil {
+            return err
+        }
+    }
+
+    if err := exec(fmt.Sprintf(`DROP TABLE %s CASCADE`, live)); err != nil {
+        return err
+    }
+    if err := exec(fmt.Sprintf(`ALTER TABLE %s RENAME TO "%s"`, staging, table)); err != nil {
+        return err
+    }
+    for _, s := range meta.sequences {
+        if !s.identity {
+            continue
+        }
+        var seq string
+        if err := tx.QueryRowContext(ctx, `SELECT pg_get_serial_sequence($1, $2)`, live, s.column).Scan(&seq); err != nil {
+            return fmt.Errorf("последовательность %s.%s: %v", table, s.column, err)
+        }
+        if err := exec(fmt.Sprintf(`ALTER SEQUENCE %s RENAME TO "%s"`, seq, s.relname)); err != nil {
+            return err
+        }
+    }
+
+    // Индексы и ограничения получают прежние имена
+    for i, idx := range meta.indexes {
+        tmp := reloadName(table, fmt.Sprintf("%s_i%d", reloadSuffix, i))
+        var q string
+        switch idx.conType {
+        case "p", "u":
+            kind := "PRIMARY KEY"
+            if idx.conType == "u" {
+                kind = "UNIQUE"
+            }
+            q = fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT "%s" %s USING INDEX "%s"`, live, idx.conName, kind, tmp)
+            if idx.deferrable {
+                q += " DEFERRABLE"
+                if idx.deferred {
+                    q += " INITIALLY DEFERRED"
+                }
+            }
+        case "x":
+            q = fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT "%s" %s`, live, idx.conName, idx.conDef)
+        default:
+            q = fmt.Sprintf(`ALTER INDEX "%s"."%s" RENAME TO "%s"`, schema, tmp, idx.name)
+        }
+        if err := exec(q); err != nil {
+            return err
+        }
+        if idx.clustered {
+            name := idx.name
+            if idx.conName != "" {
+                name = idx.conName
+            }
+            if err := exec(fmt.Sprintf(`ALTER TABLE %s CLUSTER ON "%s"`, live, name)); err != nil {
+                return err
+            }
+        }
+    }
+
+    for _, v := range meta.views {
+        var q string
+        if v.kind == "m" {
+            q = fmt.Sprintf(`CREATE MATERIALIZED VIEW %s`, v.name)
+        } else {
+            q = fmt.Sprintf(`CREATE VIEW %s`, v.name)
+        }
+        if v.options != "" {
+            q += " WITH (" + v.options + ")"
+        }
+        if err := exec(q + " AS " + v.def); err != nil {
+            return err
+        }
+        for _, s := range v.extras {
+            if err := exec(s); err != nil {
+                return err
+            }
+        }
+    }
+    for _, fk := range meta.fks {
+        if err := exec(fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT "%s" %s NOT VALID`, fk.table, fk.name, fk.def)); err != nil {
+            return err
+        }
+    }
+    for _, s := range meta.extras {
+        if err := exec(s); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
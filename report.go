@@ -0,0 +1,170 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Отчёт о проходе (runSync): какие чанки и таблицы остались несинхронизированными и почему.
+// Сюда попадают ошибки, которые не удалось исправить повтором (см. retryTransient):
+// чанк с такой ошибкой пропускается, остальная таблица синхронизируется дальше.
+// В конце прохода отчёт кратко пишется в лог и, если задан --report-file, сохраняется в JSON.
+
+// reportFailure — чанк или таблица, оставшиеся несинхронизированными
+type reportFailure struct {
+    Table      string    `json:"table"`
+    Stage      string    `json:"stage"` // read_main | read_standin | apply | table
+    ChunkStart *int64    `json:"chunk_start,omitempty"`
+    ChunkEnd   *int64    `json:"chunk_end,omitempty"`
+    SQLState   string    `json:"sqlstate,omitempty"`
+    Error      string    `json:"error"`
+    At         time.Time `json:"at"`
+}
+
+// runReport — отчёт одного прохода; chunkFailed и tableFailed nil-безопасны
+type runReport struct {
+    mu         sync.Mutex
+    RunID      string          `json:"run_id"`
+    Job        string          `json:"job,omitempty"`
+    StartedAt  time.Time       `json:"started_at"`
+    FinishedAt time.Time       `json:"finished_at"`
+    Status     string          `json:"status"` // ok | partial | failed | interrupted
+    Error      string          `json:"error,omitempty"`
+    Failures   []reportFailure `json:"failures"`
+}
+
+type reportKey struct{}
+
+func newRunReport(runID, job string) *runReport {
+    return &runReport{RunID: runID, Job: job, StartedAt: time.Now(), Failures: []reportFailure{}}
+}
+
+// withRunReport — кладёт отчёт прохода в контекст
+func withRunReport(ctx context.Context, r *runReport) context.Context {
+    return context.WithValue(ctx, reportKey{}, r)
+}
+
+// reportFrom — отчёт из контекста; nil вне прохода
+func reportFrom(ctx context.Context) *runReport {
+    r, _ := ctx.Value(reportKey{}).(*runReport)
+    return r
+}
+
+// chunkFailed — чанк [start, end] таблицы пропущен из-за ошибки на этапе stage
+func (r *runReport) chunkFailed(table, stage string, start, end int64, err error) {
+    r.add(reportFailure{Table: table, Stage: stage, ChunkStart: &start, ChunkEnd: &end, SQLState: sqlState(err), Error: err.Error()})
+}
+
+// tableFailed — таблица не синхронизирована; остановка прохода ошибкой не считается
+func (r *runReport) tableFailed(table string, err error) {
+    if errors.Is(err, errShutdown) {
+        return
+    }
+    r.add(reportFailure{Table: table, Stage: "table", SQLState: sqlState(err), Error: err.Error()})
+}
+
+// hasFailures — есть ли в отчёте пропущенные чанки таблицы (nil-безопасно)
+func (r *runReport) hasFailures(table string) bool {
+    if r == nil {
+        return false
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, f := range r.Failures {
+        if f.Table == table {
+            return true
+        }
+    }
+    return false
+}
+
+func (r *runReport) add(f reportFailure) {
+    if r == nil {
+        return
+    }
+    f.At = time.Now()
+    r.mu.Lock()
+    r.Failures = append(r.Failures, f)
+    r.mu.Unlock()
+}
+
+// finish — закрывает отчёт с итогом прохода err, пишет сводку в лог и файл --report-file
+func (r *runReport) finish(ctx context.Context, cfg *Config, err error) {
+    r.mu.Lock()
+    r.FinishedAt = time.Now()
+    switch {
+    case err == nil && len(r.Failures) > 0:
+        // Проход дошёл до конца, но часть чанков или таблиц не синхронизирована
+        r.Status = "partial"
+    case err == nil:
+        r.Status = "ok"
+    case errors.Is(err, errShutdown):
+        r.Status = "interrupted"
+    default:
+        r.Status = "failed"
+    }
+    if err != nil {
+        r.Error = err.Error()
+    }
+    var tables []string
+    for _, f := range r.Failures {
+        if !inSlice(tables, f.Table) {
+            tables = append(tables, f.Table)
+        }
+    }
+    failures := len(r.Failures)
+    data, jerr := json.MarshalIndent(r, "", "  ")
+    r.mu.Unlock()
+
+    if failures > 0 {
+        logFrom(ctx).Warn(T("run report: some chunks or tables were not synced"), "status", r.Status,
+            "failures", failures, "tables", tables)
+    }
+    if cfg.ReportFile == "" {
+        return
+    }
+    path := reportPath(cfg)
+    if jerr == nil {
+        jerr = writeFileAtomic(path, append(data, '\n'))
+    }
+    if jerr != nil {
+        logFrom(ctx).Warn(T("failed to write run report"), "path", path, "error", jerr)
+        return
+    }
+    // Сводка пишется с тем же уровнем, что и итог: неполный проход — предупреждение
+    level := slog.LevelInfo
+    if r.Status != "ok" {
+        level = slog.LevelWarn
+    }
+    logFrom(ctx).Log(ctx, level, T("run report written"), "path", path, "status", r.Status, "failures", failures)
+}
+
+// reportPath — файл отчёта; у заданий daemon-режима к имени добавляется задание: report-<job>.json
+func reportPath(cfg *Config) string {
+    if cfg.JobName == "" {
+        return cfg.ReportFile
+    }
+    ext := filepath.Ext(cfg.ReportFile)
+    return strings.TrimSuffix(cfg.ReportFile, ext) + "-" + cfg.JobName + ext
+}
+
+// writeFileAtomic — запись через временный файл и rename: читатель не увидит отчёт наполовину
+func writeFileAtomic(path string, data []byte) error {
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o644); err != nil {
+        return err
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("rename %s: %v", tmp, err)
+    }
+    return nil
+}
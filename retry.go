@@ -0,0 +1,124 @@
+package main
+
+import (
+    "context"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand/v2"
+    "net"
+    "strings"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgconn"
+)
+
+// Повтор при временных ошибках. Транзакция применения чанка в standin, прерванная
+// обрывом соединения, конфликтом сериализации или deadlock, откатывается целиком,
+// поэтому её можно безопасно выполнить ещё раз. Ошибки различаются по SQLSTATE;
+// между попытками — экспоненциальная пауза со случайным разбросом (--retry-backoff,
+// удваивается до --retry-max-backoff), всего не больше --retry-attempts попыток.
+// Чтение main не повторяется: оно идёт в транзакции снимка, которая после ошибки не пригодна.
+
+// retryPolicy — параметры повторов; nil — одна попытка
+type retryPolicy struct {
+    attempts   int
+    backoff    time.Duration
+    maxBackoff time.Duration
+}
+
+type retryKey struct{}
+
+// newRetryPolicy — nil, если повторы выключены (--retry-attempts=1)
+func newRetryPolicy(cfg *Config) *retryPolicy {
+    if cfg.RetryAttempts <= 1 {
+        return nil
+    }
+    return &retryPolicy{attempts: cfg.RetryAttempts, backoff: cfg.RetryBackoff, maxBackoff: cfg.RetryMaxBackoff}
+}
+
+// withRetryPolicy — кладёт параметры повторов в контекст
+func withRetryPolicy(ctx context.Context, p *retryPolicy) context.Context {
+    return context.WithValue(ctx, retryKey{}, p)
+}
+
+// sqlState — код SQLSTATE ошибки сервера; "" — ошибка не от PostgreSQL
+func sqlState(err error) string {
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        return pgErr.Code
+    }
+    return ""
+}
+
+// isTransient — можно ли повторить операцию, упавшую с ошибкой err
+func isTransient(err error) bool {
+    if err == nil || errors.Is(err, errSafety) || errors.Is(err, errShutdown) ||
+        errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        return false
+    }
+    switch code := sqlState(err); {
+    case code == "40001", // serialization_failure
+        code == "40P01", // deadlock_detected
+        code == "55P03", // lock_not_available (lock_timeout)
+        code == "53300", // too_many_connections
+        code == "57P01", // admin_shutdown
+        code == "57P02", // crash_shutdown
+        code == "57P03", // cannot_connect_now
+        strings.HasPrefix(code, "08"): // connection_exception
+        return true
+    case code != "":
+        // Остальные ошибки сервера (нарушение ограничений, типы, права) повтор не исправит
+        return false
+    }
+    // Без SQLSTATE: соединение оборвалось до ответа сервера
+    var netErr net.Error
+    return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+        errors.As(err, &netErr) || pgconn.SafeToRetry(err)
+}
+
+// retryTransient — выполняет fn, повторяя её при временных ошибках. Пауза между попытками
+// прерывается остановкой по ctx; fn сама решает, в каком контексте выполнять запросы.
+func retryTransient(ctx context.Context, op string, fn func() error) error {
+    p, _ := ctx.Value(retryKey{}).(*retryPolicy)
+    attempts := 1
+    if p != nil {
+        attempts = p.attempts
+    }
+    var err error
+    for attempt := 1; ; attempt++ {
+        if err = fn(); err == nil || !isTransient(err) {
+            return err
+        }
+        if attempt >= attempts {
+            if attempts > 1 {
+                return fmt.Errorf("%s: %d попыток не помогли: %w", op, attempts, err)
+            }
+            return err
+        }
+        delay := p.delay(attempt)
+        metricRetries.Add(sqlState(err), 1)
+        logFrom(ctx).Warn(T("transient error, retrying"), "op", op, "attempt", attempt, "max_attempts", attempts,
+            "sqlstate", sqlState(err), "delay", delay.Round(time.Millisecond), "error", err)
+        if serr := sleepCtx(ctx, delay); serr != nil {
+            return serr
+        }
+    }
+}
+
+// delay — пауза перед попыткой attempt+1: backoff·2^(attempt-1), не больше maxBackoff,
+// случайно от половины до полной величины, чтобы воркеры не повторяли запросы одновременно
+func (p *retryPolicy) delay(attempt int) time.Duration {
+    d := p.backoff
+    for i := 1; i < attempt && (p.maxBackoff <= 0 || d < p.maxBackoff); i++ {
+        d *= 2
+    }
+    if p.maxBackoff > 0 && d > p.maxBackoff {
+        d = p.maxBackoff
+    }
+    if d <= 0 {
+        return 0
+    }
+    return d/2 + rand.N(d/2+1)
+}
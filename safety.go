@@ -0,0 +1,146 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+)
+
+// Защитные пороги для разрушающих изменений в standin. Ошибочный DSN или пустая main
+// не должны превращаться в массовое удаление: при нарушении порога проход останавливается.
+//   --max-delete-ratio    — доля строк таблицы standin, которую можно удалить за проход;
+//   --max-drop-tables     — сколько лишних таблиц может удалить clean-extra за проход;
+//   --allow-empty-main    — разрешить проход, когда в main нет таблиц, а в standin есть;
+//   --protected-tables    — таблицы, которые нельзя удалять (DROP) и очищать целиком:
+//                           за проход из них нельзя удалить все строки, что были на начало.
+
+// errSafety — нарушен защитный порог; такая ошибка всегда останавливает проход
+var errSafety = errors.New("сработала защита от разрушающих изменений")
+
+// deleteGuard — счётчик удалённых за проход строк одной таблицы
+type deleteGuard struct {
+    cfg       *Config
+    schema    string
+    table     string
+    protected bool // таблица из --protected-tables
+
+    mu      sync.Mutex
+    total   int64 // строк в standin на начало (-1 — ещё не считали)
+    deleted int64
+}
+
+type deleteGuardKey struct{}
+
+// withDeleteGuard — подключает проверку --max-delete-ratio и --protected-tables для таблицы
+func withDeleteGuard(ctx context.Context, cfg *Config, tableName string) context.Context {
+    sTable := standinTable(cfg, tableName)
+    protected := isProtected(cfg, sTable)
+    if cfg.MaxDeleteRatio <= 0 && !protected {
+        return ctx
+    }
+    g := &deleteGuard{cfg: cfg, schema: cfg.StandinSchema, table: sTable, protected: protected, total: -1}
+    return context.WithValue(ctx, deleteGuardKey{}, g)
+}
+
+// checkDeleteAllowed — вызывается перед каждым пакетом удалений в standin.
+// Строки standin считаются один раз, при первом удалении: без удалений проверка ничего не стоит.
+func checkDeleteAllowed(ctx context.Context, n int) error {
+    g, _ := ctx.Value(deleteGuardKey{}).(*deleteGuard)
+    if g == nil || n == 0 {
+        return nil
+    }
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
+    if g.total < 0 {
+        // Для защищённой таблицы оценки pg_class мало: сравниваем с точным числом строк
+        count := standinRowCount
+        if g.protected {
+            count = standinExactRowCount
+        }
+        total, err := count(ctx, g.schema, g.table)
+        if err != nil {
+            return fmt.Errorf("подсчёт строк standin %s для защитных порогов: %v", g.table, err)
+        }
+        g.total = total
+    }
+
+    if g.protected && g.deleted+int64(n) >= g.total {
+        return fmt.Errorf("%w: таблица %s входит в --protected-tables, а проход удалил бы из неё "+
+            "все %d строк standin. Проверьте DSN, --filter и данные main; "+
+            "если очистка ожидаема — уберите таблицу из списка защищённых",
+            errSafety, g.table, g.total)
+    }
+    if g.cfg.MaxDeleteRatio <= 0 {
+        g.deleted += int64(n)
+        return nil
+    }
+
+    limit := int64(g.cfg.MaxDeleteRatio * float64(g.total))
+    if g.deleted+int64(n) > limit {
+        return fmt.Errorf("%w: в таблице %s пришлось бы удалить %d из %d строк standin, "+
+            "это больше --max-delete-ratio=%g (%d строк). Проверьте DSN и данные main; "+
+            "если удаление ожидаемо — поднимите порог или задайте --max-delete-ratio=0",
+            errSafety, g.table, g.deleted+int64(n), g.total, g.cfg.MaxDeleteRatio, limit)
+    }
+    g.deleted += int64(n)
+    return nil
+}
+
+// standinRowCount — число строк таблицы standin: оценка pg_class, а для
+// ни разу не анализированных таблиц — точный COUNT(*)
+func standinRowCount(ctx context.Context, schema, table string) (int64, error) {
+    q := `
+SELECT c.reltuples::bigint
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1 AND c.relname = $2
+`
+    var n int64
+    if err := standinDB.QueryRowContext(ctx, q, schema, table).Scan(&n); err != nil {
+        return 0, err
+    }
+    if n > 0 {
+        return n, nil
+    }
+    return standinExactRowCount(ctx, schema, table)
+}
+
+// standinExactRowCount — точное число строк таблицы standin (COUNT(*))
+func standinExactRowCount(ctx context.Context, schema, table string) (int64, error) {
+    var n int64
+    err := standinDB.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(*) FROM "%s"."%s"`, schema, table)).Scan(&n)
+    return n, err
+}
+
+// checkDropAllowed — проверяет план clean-extra до того, как что-либо удалено
+func checkDropAllowed(cfg *Config, toDrop []string) error {
+    for _, t := range toDrop {
+        if isProtected(cfg, t) {
+            return fmt.Errorf("%w: таблица %s есть в standin, но не в main, и входит в --protected-tables — "+
+                "удалять её нельзя. Уберите её из clean-extra (или из списка защищённых)", errSafety, t)
+        }
+    }
+    if cfg.MaxDropTables > 0 && len(toDrop) > cfg.MaxDropTables {
+        return fmt.Errorf("%w: clean-extra удалил бы %d таблиц standin (%v), больше --max-drop-tables=%d",
+            errSafety, len(toDrop), toDrop, cfg.MaxDropTables)
+    }
+    return nil
+}
+
+// checkEmptyMain — отказ от синхронизации, когда main пуста, а standin нет:
+// чаще всего это ошибка в DSN или --schema, а не реальное удаление всех таблиц
+func checkEmptyMain(cfg *Config, mainTables, standinTables []string) error {
+    if len(mainTables) > 0 || len(standinTables) == 0 || cfg.AllowEmptyMain {
+        return nil
+    }
+    return fmt.Errorf("%w: в схеме %q основной БД нет таблиц, а в standin их %d. "+
+        "Проверьте --main-dsn и --schema; если main действительно пуста — запустите с --allow-empty-main",
+        errSafety, cfg.Schema, len(standinTables))
+}
+
+// isProtected — таблица из --protected-tables
+func isProtected(cfg *Config, table string) bool {
+    return inSlice(cfg.ProtectedTables, table)
+}
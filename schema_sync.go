@@ -5,14 +5,14 @@ import (
     "bytes"
     "context"
     "fmt"
-    "log"
     "os/exec"
     "strings"
 )
 
 // SyncSchema — синхронизирует структуру (DDL) через pg_dump.
-func SyncSchema(cfg *Config) error {
-    log.Println("[Schema] Синхронизация структуры...")
+func SyncSchema(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "schema", "schema", cfg.Schema)
+    logFrom(ctx).Info(T("schema sync started"))
 
     // Формируем аргументы для pg_dump
     args := []string{
@@ -24,10 +24,10 @@ func SyncSchema(cfg *Config) error {
     }
 
     // Выводим debug-информацию (полезно при отладке)
-    log.Printf("[DEBUG] Выполняем: %s %v", cfg.PgDumpPath, args)
+    logFrom(ctx).Debug(T("running pg_dump"), "cmd", cfg.PgDumpPath, "args", args)
 
     // Запускаем pg_dump
-    dumpCmd := exec.Command(cfg.PgDumpPath, args...)
+    dumpCmd := exec.CommandContext(ctx, cfg.PgDumpPath, args...)
 
     var out bytes.Buffer
     var stderr bytes.Buffer
@@ -38,35 +38,49 @@ func SyncSchema(cfg *Config) error {
         return fmt.Errorf("pg_dump ошибка: %v (stderr=%s)", err, stderr.String())
     }
 
-    ddl := out.String()
+    // Объекты расширений остаются в своей схеме (см. remapDDL)
+    var extMembers map[string]bool
+    if remapped(cfg) {
+        var err error
+        if extMembers, err = extensionMembers(ctx, cfg.Schema); err != nil {
+            return err
+        }
+    }
+    ddl := remapDDL(cfg, stripCaptureTriggers(out.String()), extMembers)
     if ddl == "" {
-        log.Println("[Schema] pg_dump вернул пустой результат. Возможно, в схеме нет объектов.")
+        logFrom(ctx).Warn(T("pg_dump returned empty output, schema may have no objects"))
         return nil
     }
 
     // Логируем общий объём полученного DDL
-    log.Printf("[Schema] Получен DDL размером ~%d байт", len(ddl))
+    logFrom(ctx).Info(T("DDL received"), "bytes", len(ddl))
+
+    // Схема standin может отличаться от main (--standin-schema) и ещё не существовать
+    if remapped(cfg) {
+        if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS "%s"`, cfg.StandinSchema)); err != nil {
+            return fmt.Errorf("CREATE SCHEMA %s: %v", cfg.StandinSchema, err)
+        }
+    }
 
     // Выбор способа применения DDL
     if cfg.ForcePsqlApply {
         // Применяем DDL через psql
-        log.Println("[Schema] Применяем DDL через psql...")
-        if err := applyDDLviaPSQL(cfg, ddl); err != nil {
+        logFrom(ctx).Info(T("applying DDL via psql"))
+        if err := applyDDLviaPSQL(ctx, cfg, ddl); err != nil {
             return fmt.Errorf("ошибка applyDDLviaPSQL: %v", err)
         }
     } else {
-        log.Println("[Schema] Применяем DDL через ExecContext (построчная обработка)...")
-        if err := applyDDLToStandin(ddl); err != nil {
+        logFrom(ctx).Info(T("applying DDL statement by statement"))
+        if err := applyDDLToStandin(ctx, ddl); err != nil {
             return fmt.Errorf("ошибка applyDDLToStandin: %v", err)
         }
     }
 
-    log.Println("[Schema] Структура синхронизирована.")
+    logFrom(ctx).Info(T("schema sync finished"))
     return nil
 }
 
-func applyDDLToStandin(ddl string) error {
-    ctx := context.Background()
+func applyDDLToStandin(ctx context.Context, ddl string) error {
     scanner := bufio.NewScanner(strings.NewReader(ddl))
 
     var stmtBuilder strings.Builder
@@ -79,12 +93,16 @@ func applyDDLToStandin(ddl string) error {
         stmtBuilder.WriteString(line + "\n")
 
         if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ';' {
+            // Между выражениями проверяем, не попросили ли остановиться
+            if err := checkStop(ctx); err != nil {
+                return err
+            }
             statement := stmtBuilder.String()
 
             _, err := standinDB.ExecContext(ctx, statement)
             if err != nil {
                 // Можно сделать return err, если хотим прерывать при ошибке
-                log.Printf("[WARN] DDL exec ошибка: %v\nSQL:\n%s\n", err, statement)
+                logFrom(ctx).Warn(T("DDL statement failed"), "error", err, "sql", statement)
             }
             stmtBuilder.Reset()
         }
@@ -93,9 +111,9 @@ func applyDDLToStandin(ddl string) error {
 }
 
 // applyDDLviaPSQL — альтернатива: запускаем psql -f - и подаём DDL на stdin
-func applyDDLviaPSQL(cfg *Config, ddl string) error {
+func applyDDLviaPSQL(ctx context.Context, cfg *Config, ddl string) error {
 
-    cmd := exec.Command("psql", cfg.StandinDSN)
+    cmd := exec.CommandContext(ctx, "psql", cfg.StandinDSN)
 
     stdin, err := cmd.StdinPipe()
     if err != nil {
@@ -115,7 +133,7 @@ func applyDDLviaPSQL(cfg *Config, ddl string) error {
 
     // Логируем вывод psql, если он не пуст
     if len(out) > 0 {
-        log.Printf("[psql output]\n%s", string(out))
+        logFrom(ctx).Info(T("psql output"), "output", string(out))
     }
     return nil
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+)
+
+// errShutdown — синхронизация прервана по сигналу остановки на границе чанка
+var errShutdown = errors.New("остановка по сигналу: текущий чанк завершён, дальше не идём")
+
+// checkStop — проверка между чанками/таблицами: не пора ли остановиться.
+// Транзакции применения изменений сюда не попадают — они идут в applyContext
+// и всегда доводятся до COMMIT/ROLLBACK.
+func checkStop(ctx context.Context) error {
+    switch ctx.Err() {
+    case nil:
+        return nil
+    case context.Canceled:
+        return errShutdown
+    default:
+        return fmt.Errorf("превышен таймаут: %w", ctx.Err())
+    }
+}
+
+// applyContext — контекст для транзакции применения изменений в standinDB:
+// отмена родителя (Ctrl-C, SIGTERM, таймаут таблицы) её не обрывает,
+// а от зависших запросов защищает statement_timeout на соединении.
+func applyContext(ctx context.Context) context.Context {
+    return context.WithoutCancel(ctx)
+}
+
+// snapshotContext — контекст запросов в транзакциях снимка main (mainTx и транзакции воркеров).
+// Отменённый запрос обрывает всю транзакцию PostgreSQL — остальные таблицы на ней падали бы
+// с 25P02, — а отмена контекста BeginTx и вовсе её откатывает. Поэтому ни сигнал остановки,
+// ни --table-timeout до этих запросов не доходят: остановка проверяется checkStop между чанками,
+// таймаут таблицы ограничивает запросы к standin.
+func snapshotContext(ctx context.Context) context.Context {
+    return context.WithoutCancel(ctx)
+}
+
+// disableStatementTimeout — снимает --statement-timeout внутри транзакции снимка: запрос,
+// прерванный по таймауту, тоже обрывает транзакцию. Таймаут действует на standin и на main вне снимка.
+func disableStatementTimeout(ctx context.Context, tx *sql.Tx) error {
+    if _, err := tx.ExecContext(snapshotContext(ctx), `SET LOCAL statement_timeout = 0`); err != nil {
+        return fmt.Errorf("SET LOCAL statement_timeout: %v", err)
+    }
+    return nil
+}
+
+// recordInterruption — запоминает в pgsyncer.sync_state, до какого PK таблица
+// успела синхронизироваться, чтобы следующий запуск с --resume продолжил с этого места.
+func recordInterruption(ctx context.Context, schema, table string, lastDone int64) error {
+    q := fmt.Sprintf(`
+INSERT INTO %s.sync_state (schema_name, table_name, mode, resume_from, interrupted_at)
+VALUES ($1, $2, 'chunks', $3, now())
+ON CONFLICT (schema_name, table_name)
+DO UPDATE SET resume_from = EXCLUDED.resume_from,
+              interrupted_at = EXCLUDED.interrupted_at`, stateSchema)
+    _, err := standinDB.ExecContext(applyContext(ctx), q, schema, table, lastDone)
+    return err
+}
+
+// loadResumePoint — точка остановки прерванного запуска (ok=false, если её нет)
+func loadResumePoint(ctx context.Context, schema, table string) (int64, bool, error) {
+    var pos sql.NullInt64
+    q := fmt.Sprintf(`SELECT resume_from FROM %s.sync_state WHERE schema_name = $1 AND table_name = $2`, stateSchema)
+    err := standinDB.QueryRowContext(ctx, q, schema, table).Scan(&pos)
+    if err == sql.ErrNoRows {
+        return 0, false, nil
+    }
+    if err != nil {
+        return 0, false, err
+    }
+    return pos.Int64, pos.Valid, nil
+}
+
+// clearResumePoint — таблица дошла до конца, точка остановки больше не нужна
+func clearResumePoint(ctx context.Context, schema, table string) error {
+    q := fmt.Sprintf(`UPDATE %s.sync_state SET resume_from = NULL WHERE schema_name = $1 AND table_name = $2`, stateSchema)
+    _, err := standinDB.ExecContext(ctx, q, schema, table)
+    return err
+}
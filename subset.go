@@ -0,0 +1,393 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// Ссылочно-целостное подмножество (команда subset): выборка начинается с корневых строк
+// одной таблицы (--subset-root "customers=country = 'DE'", --subset-limit 1000) и
+// расширяется по внешним ключам из pg_constraint:
+//   1) вниз — все зависимые строки: заказы выбранных клиентов, позиции этих заказов и т. д.;
+//   2) вверх — все строки, на которые ссылается уже выбранное: товары позиций, их категории...
+// Строки, попавшие в выборку на шаге 2, своих зависимых не тянут — иначе через общий
+// справочник подмножество разрастается до всей базы. Итог замкнут: каждый внешний ключ
+// выбранной строки указывает на выбранную строку.
+// Выборка строится в снимке REPEATABLE READ основной БД во временных таблицах с адресами
+// строк (tableoid, ctid) и копируется в standin одной транзакцией, родители раньше детей.
+
+// fkEdge — внешний ключ child(childCols) -> parent(parentCols)
+type fkEdge struct {
+    name       string
+    child      string
+    parent     string
+    childCols  []string
+    parentCols []string
+}
+
+// subsetRoot — корень выборки: таблица и условие (пусто — все строки)
+type subsetRoot struct {
+    table string
+    where string
+}
+
+// parseSubsetRoot — разбирает --subset-root "таблица[=условие]"
+func parseSubsetRoot(spec string) (subsetRoot, error) {
+    r := subsetRoot{table: strings.TrimSpace(spec)}
+    if eq := strings.Index(spec, "="); eq >= 0 {
+        r.table, r.where = strings.TrimSpace(spec[:eq]), strings.TrimSpace(spec[eq+1:])
+    }
+    if r.table == "" {
+        return r, fmt.Errorf("неверный --subset-root %q (ожидается таблица[=условие])", spec)
+    }
+    return r, nil
+}
+
+// runSubset — команда subset: структура (если --sync-schema), затем подмножество данных
+func runSubset(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "run_id", newRunID())
+    if cfg.SyncSchema {
+        if err := SyncSchema(ctx, cfg); err != nil {
+            metricErrors.Add("schema", 1)
+            return fmt.Errorf("SyncSchema ошибка: %w", err)
+        }
+    }
+    if err := syncSubset(ctx, cfg); err != nil {
+        metricErrors.Add("data", 1)
+        return fmt.Errorf("syncSubset ошибка: %w", err)
+    }
+    return nil
+}
+
+// syncSubset — собирает замкнутое по внешним ключам подмножество main и копирует его в standin
+func syncSubset(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "subset", "schema", cfg.Schema)
+    root, err := parseSubsetRoot(cfg.SubsetRoot)
+    if err != nil {
+        return err
+    }
+
+    // Временные таблицы создаются в этой же транзакции, поэтому она не read-only
+    mainTx, err := mainDB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+    if err != nil {
+        return fmt.Errorf("BeginTx mainDB: %v", err)
+    }
+    defer mainTx.Rollback()
+
+    tables, err := listTables(ctx, mainTx, cfg.Schema)
+    if err != nil {
+        return fmt.Errorf("listTables(mainDB): %v", err)
+    }
+    // Секционированные таблицы обходятся через корень: внешние ключи объявлены на нём,
+    // а строки в standin разложит по секциям сам PostgreSQL
+    parts, err := loadPartitions(ctx, mainTx, cfg.Schema)
+    if err != nil {
+        return fmt.Errorf("loadPartitions(mainDB): %v", err)
+    }
+    tables = parts.selectLevel(tables, "parent")
+    if !inSlice(tables, root.table) {
+        return fmt.Errorf("корневая таблица %s.%s не найдена в main", cfg.Schema, root.table)
+    }
+    edges, err := listForeignKeys(ctx, mainTx, cfg.Schema)
+    if err != nil {
+        return fmt.Errorf("listForeignKeys: %v", err)
+    }
+    logFrom(ctx).Info(T("subset extraction started"), "root", root.table, "where", root.where,
+        "limit", cfg.SubsetLimit, "tables", len(tables), "foreign_keys", len(edges))
+
+    // Таблица выборки на каждую таблицу схемы: адреса выбранных строк
+    sel := make(map[string]string, len(tables))
+    for i, t := range tables {
+        sel[t] = fmt.Sprintf("pgsyncer_subset_%d", i)
+        q := fmt.Sprintf(`CREATE TEMP TABLE %s (rel oid, tid tid, PRIMARY KEY (rel, tid)) ON COMMIT DROP`, sel[t])
+        if _, err := mainTx.ExecContext(ctx, q); err != nil {
+            return fmt.Errorf("временная таблица выборки для %s: %v", t, err)
+        }
+    }
+
+    // Корневые строки
+    where, limit := "TRUE", ""
+    if root.where != "" {
+        where = "(" + root.where + ")"
+    }
+    if cfg.SubsetLimit > 0 {
+        limit = fmt.Sprintf("LIMIT %d", cfg.SubsetLimit)
+    }
+    q := fmt.Sprintf(`INSERT INTO %s SELECT tableoid, ctid FROM "%s"."%s" WHERE %s %s`,
+        sel[root.table], cfg.Schema, root.table, where, limit)
+    res, err := mainTx.ExecContext(ctx, q)
+    if err != nil {
+        return fmt.Errorf("корневые строки %s: %v", root.table, err)
+    }
+    n, _ := res.RowsAffected()
+    logFrom(ctx).Info(T("subset root rows selected"), "table", root.table, "rows", n)
+
+    // 1) Вниз: зависимые строки; 2) вверх: строки, на которые ссылаются
+    if err := expandSubset(ctx, cfg, mainTx, edges, sel, true); err != nil {
+        return err
+    }
+    if err := expandSubset(ctx, cfg, mainTx, edges, sel, false); err != nil {
+        return err
+    }
+
+    order, cyclic := subsetLoadOrder(tables, edges)
+    if len(cyclic) > 0 {
+        logFrom(ctx).Warn(T("foreign key cycle, loading these tables with deferred constraints"), "tables", cyclic)
+    }
+    if err := copySubset(ctx, cfg, mainTx, order, sel); err != nil {
+        return err
+    }
+    return mainTx.Commit()
+}
+
+// listForeignKeys — внешние ключи между таблицами схемы. Для секционированных таблиц
+// берутся только ключи самой таблицы, без унаследованных секциями копий.
+func listForeignKeys(ctx context.Context, mainTx *sql.Tx, schema string) ([]fkEdge, error) {
+    q := `
+SELECT c.conname, cl.relname, pl.relname,
+       json_agg(ca.attname ORDER BY k.ord)::text,
+       json_agg(pa.attname ORDER BY k.ord)::text
+FROM pg_constraint c
+JOIN pg_class cl ON cl.oid = c.conrelid
+JOIN pg_namespace cn ON cn.oid = cl.relnamespace
+JOIN pg_class pl ON pl.oid = c.confrelid
+JOIN pg_namespace pn ON pn.oid = pl.relnamespace
+CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(ck, pk, ord)
+JOIN pg_attribute ca ON ca.attrelid = c.conrelid AND ca.attnum = k.ck
+JOIN pg_attribute pa ON pa.attrelid = c.confrelid AND pa.attnum = k.pk
+WHERE c.contype = 'f'
+  AND c.conparentid = 0
+  AND cn.nspname = $1
+  AND pn.nspname = $1
+GROUP BY c.oid, c.conname, cl.relname, pl.relname
+ORDER BY cl.relname, c.conname
+`
+    rows, err := mainTx.QueryContext(ctx, q, schema)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var edges []fkEdge
+    for rows.Next() {
+        var e fkEdge
+        var childCols, parentCols string
+        if err := rows.Scan(&e.name, &e.child, &e.parent, &childCols, &parentCols); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal([]byte(childCols), &e.childCols); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal([]byte(parentCols), &e.parentCols); err != nil {
+            return nil, err
+        }
+        edges = append(edges, e)
+    }
+    return edges, rows.Err()
+}
+
+// expandSubset — расширяет выборку по всем внешним ключам до неподвижной точки.
+// down=true добавляет дочерние строки выбранных родителей, down=false — родителей выбранных детей.
+func expandSubset(ctx context.Context, cfg *Config, mainTx *sql.Tx, edges []fkEdge, sel map[string]string, down bool) error {
+    direction := "up"
+    if down {
+        direction = "down"
+    }
+    for pass := 1; ; pass++ {
+        if err := checkStop(ctx); err != nil {
+            return err
+        }
+        var added int64
+        for _, e := range edges {
+            if sel[e.child] == "" || sel[e.parent] == "" {
+                continue
+            }
+            conds := make([]string, len(e.childCols))
+            for i := range e.childCols {
+                conds[i] = fmt.Sprintf(`c."%s" = p."%s"`, e.childCols[i], e.parentCols[i])
+            }
+            // Добавляемая сторона (a) ищется по наличию пары на выбранной стороне (b)
+            addTable, addAlias, fromTable, fromAlias := e.child, "c", e.parent, "p"
+            if !down {
+                addTable, addAlias, fromTable, fromAlias = e.parent, "p", e.child, "c"
+            }
+            q := fmt.Sprintf(`
+INSERT INTO %[1]s
+SELECT %[3]s.tableoid, %[3]s.ctid FROM "%[2]s"."%[4]s" %[3]s
+WHERE EXISTS (
+    SELECT 1 FROM "%[2]s"."%[5]s" %[6]s
+    JOIN %[7]s s ON s.rel = %[6]s.tableoid AND s.tid = %[6]s.ctid
+    WHERE %[8]s
+)
+ON CONFLICT DO NOTHING`,
+                sel[addTable], cfg.Schema, addAlias, addTable, fromTable, fromAlias, sel[fromTable],
+                strings.Join(conds, " AND "))
+            res, err := mainTx.ExecContext(ctx, q)
+            if err != nil {
+                return fmt.Errorf("обход внешнего ключа %s (%s -> %s): %v", e.name, e.child, e.parent, err)
+            }
+            n, _ := res.RowsAffected()
+            if n > 0 {
+                logFrom(ctx).Debug(T("subset rows added via foreign key"), "direction", direction,
+                    "constraint", e.name, "table", addTable, "rows", n)
+            }
+            added += n
+        }
+        logFrom(ctx).Debug(T("subset expansion pass finished"), "direction", direction, "pass", pass, "rows", added)
+        if added == 0 {
+            return nil
+        }
+    }
+}
+
+// subsetLoadOrder — порядок загрузки: родители раньше детей (ссылки таблицы на себя не в счёт).
+// Таблицы, входящие в цикл внешних ключей, идут в конце по алфавиту и возвращаются вторым значением.
+func subsetLoadOrder(tables []string, edges []fkEdge) ([]string, []string) {
+    parents := make(map[string]map[string]bool, len(tables))
+    for _, t := range tables {
+        parents[t] = make(map[string]bool)
+    }
+    for _, e := range edges {
+        if e.child != e.parent && parents[e.child] != nil && parents[e.parent] != nil {
+            parents[e.child][e.parent] = true
+        }
+    }
+
+    var order []string
+    placed := make(map[string]bool, len(tables))
+    for progress := true; progress; {
+        progress = false
+        for _, t := range tables {
+            if placed[t] {
+                continue
+            }
+            ready := true
+            for p := range parents[t] {
+                if !placed[p] {
+                    ready = false
+                    break
+                }
+            }
+            if ready {
+                order = append(order, t)
+                placed[t] = true
+                progress = true
+            }
+        }
+    }
+
+    var cyclic []string
+    for _, t := range tables {
+        if !placed[t] {
+            cyclic = append(cyclic, t)
+        }
+    }
+    sort.Strings(cyclic)
+    return append(order, cyclic...), cyclic
+}
+
+// copySubset — копирует выбранные строки в standin одной транзакцией в порядке order.
+// SET CONSTRAINTS ALL DEFERRED позволяет загрузить циклы внешних ключей, если они DEFERRABLE.
+func copySubset(ctx context.Context, cfg *Config, mainTx *sql.Tx, order []string, sel map[string]string) error {
+    actx := applyContext(ctx)
+    tx, err := standinDB.BeginTx(actx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err := tx.ExecContext(actx, `SET CONSTRAINTS ALL DEFERRED`); err != nil {
+        return err
+    }
+
+    var total int64
+    for _, t := range order {
+        if err := checkStop(ctx); err != nil {
+            return err
+        }
+        n, err := copySubsetTable(withLogAttrs(ctx, "table", t), cfg, mainTx, tx, t, sel[t])
+        if err != nil {
+            return fmt.Errorf("копирование подмножества %s: %v", t, err)
+        }
+        total += n
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("Commit standin: %v", err)
+    }
+    logFrom(ctx).Info(T("subset copied to standin"), "tables", len(order), "rows", total)
+    return nil
+}
+
+// copySubsetTable — читает выбранные строки таблицы из снимка main и пишет их в standin пакетами.
+// Таблицы с PK обновляются upsert'ом (повторный запуск идемпотентен), без PK — вставляются.
+func copySubsetTable(ctx context.Context, cfg *Config, mainTx, standinTx *sql.Tx, table, selTable string) (int64, error) {
+    columns, types, err := syncColumns(ctx, cfg, mainTx, table)
+    if err != nil {
+        return 0, err
+    }
+    pkCols, _ := detectPK(ctx, mainTx, cfg.Schema, table)
+    tr, err := transformFor(cfg, table, columns, types, pkCols)
+    if err != nil {
+        return 0, err
+    }
+
+    q := fmt.Sprintf(`SELECT %s FROM "%s"."%s" t JOIN %s s ON s.rel = t.tableoid AND s.tid = t.ctid`,
+        selectColumns("t", columns, castsFor(cfg, table)), cfg.Schema, table, selTable)
+    rows, err := mainTx.QueryContext(ctx, q)
+    if err != nil {
+        return 0, err
+    }
+    defer rows.Close()
+
+    // Строки буферизуются целиком: на соединении mainTx нельзя выполнять запросы,
+    // пока читается результат, а выборка subset по замыслу небольшая
+    var data [][]interface{}
+    for rows.Next() {
+        vals := make([]interface{}, len(columns))
+        ptrs := make([]interface{}, len(columns))
+        for i := range vals {
+            ptrs[i] = &vals[i]
+        }
+        if err := rows.Scan(ptrs...); err != nil {
+            return 0, err
+        }
+        tr.apply(vals)
+        data = append(data, vals)
+    }
+    if err := rows.Err(); err != nil {
+        return 0, err
+    }
+    rows.Close()
+
+    actx := applyContext(ctx)
+    batch := upsertBatchSize(len(columns), cfg.ChunkSize)
+    for start := 0; start < len(data); start += batch {
+        end := start + batch
+        if end > len(data) {
+            end = len(data)
+        }
+        if err := insertSubsetBatch(actx, standinTx, cfg.StandinSchema, standinTable(cfg, table), columns, pkCols, data[start:end]); err != nil {
+            return 0, err
+        }
+    }
+    metricRowsInserted.Add(table, float64(len(data)))
+    if len(data) > 0 {
+        logFrom(ctx).Info(T("subset table copied"), "rows", len(data))
+    }
+    return int64(len(data)), nil
+}
+
+// insertSubsetBatch — upsert пакета строк; для таблиц без PK — простой INSERT
+func insertSubsetBatch(ctx context.Context, tx *sql.Tx, schema, table string, columns, pkCols []string, batch [][]interface{}) error {
+    if len(pkCols) > 0 {
+        return doBatchUpsertTx(ctx, tx, schema, table, columns, pkCols, batch)
+    }
+    q := fmt.Sprintf(`INSERT INTO "%s"."%s" (%s) %s VALUES %s`,
+        schema, table, quoteColumns(columns), overridingClause(schema, table, columns),
+        makePlaceholderMatrix(len(batch), len(columns)))
+    _, err := tx.ExecContext(ctx, q, flatten(batch)...)
+    return err
+}
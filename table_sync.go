@@ -5,48 +5,107 @@ import (
     "crypto/md5"
     "database/sql"
     "encoding/hex"
+    "errors"
     "fmt"
-    "log"
     "strconv"
     "strings"
+    "time"
 )
 
 // syncTableData — главный вход для синхронизации одной таблицы.
-func syncTableData(cfg *Config, mainTx *sql.Tx, tableName string) error {
+func syncTableData(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
     schema := cfg.Schema
 
-    // 1) Проверяем FDW-режим
+    // 1) Перезаливка целиком (--reload-tables): копия в staging-таблицу и атомарная подмена
+    if inSlice(cfg.ReloadTables, tableName) {
+        ok, err := reloadable(ctx, cfg, tableName)
+        if err != nil {
+            return err
+        }
+        if ok {
+            return syncTableReload(ctx, cfg, mainTx, tableName)
+        }
+    }
+
+    // 2) FDW-режим: standin сам читает main через postgres_fdw
     if cfg.FDWMode {
-        log.Printf("[syncTableData] Таблица %s: FDW mode включён — пропускаем демонстрационную логику", tableName)
-        // Здесь могла бы быть реализация syncTableFDW(...).
-        return nil
+        return syncTableFDW(ctx, cfg, mainTx, tableName)
     }
 
-    // 2) Проверяем режим UpdatedAt
-    if cfg.UseUpdatedAt {
-        return syncTableByUpdatedAt(cfg, mainTx, tableName)
+    // 3) Trigger-захват: если таблица подключена и уже прошла начальную синхронизацию,
+    // переносим только ключи из журнала pgsyncer.changelog
+    if cfg.CaptureMode == "trigger" {
+        registered, initialized, err := captureState(ctx, mainTx, schema, tableName)
+        if err != nil {
+            return fmt.Errorf("captureState(%s): %v", tableName, err)
+        }
+        if registered {
+            pkCols, numericPK := detectPK(ctx, mainTx, schema, tableName)
+            if initialized {
+                return syncTableByChangelog(ctx, cfg, mainTx, tableName, pkCols)
+            }
+            logFrom(ctx).Info(T("capture not initialized yet, running full sync"))
+            complete, err := syncTableByKeys(ctx, cfg, mainTx, tableName, pkCols, numericPK)
+            if err != nil {
+                return err
+            }
+            if !complete {
+                // Журнал не очищаем: следующий запуск снова выполнит полную синхронизацию
+                logFrom(ctx).Warn(T("initial sync incomplete, capture stays uninitialized"))
+                return nil
+            }
+            return markCaptureInitialized(ctx, mainTx, schema, tableName)
+        }
+    }
+
+    // 4) Инкрементальные режимы: updated_at, xmin, commit-ts.
+    // Фильтр по изменениям не видит удалённых на main строк — их ищет detectDeletes.
+    switch cfg.IncrementalMode {
+    case "updated_at", "xmin", "commit-ts":
+        var err error
+        if cfg.IncrementalMode == "updated_at" {
+            err = syncTableByUpdatedAt(ctx, cfg, mainTx, tableName)
+        } else {
+            err = syncTableByXmin(ctx, cfg, mainTx, tableName)
+        }
+        if err != nil {
+            return err
+        }
+        return detectDeletes(ctx, cfg, mainTx, tableName)
     }
 
-    // 3) Пытаемся определить PK
-    pkCols, numericPK := detectPK(mainTx, schema, tableName)
+    // 5) Пытаемся определить PK
+    pkCols, numericPK := detectPK(ctx, mainTx, schema, tableName)
+    _, err := syncTableByKeys(ctx, cfg, mainTx, tableName, pkCols, numericPK)
+    return err
+}
+
+// syncTableByKeys — выбирает между chunk-based синхронизацией и полным диффом по виду PK.
+// complete=false — таблица в standin не равна снимку целиком: строки без PK не сопоставить,
+// часть чанков пропущена из-за ошибок (они в отчёте о проходе) или проход продолжен с --resume.
+// Начальную синхронизацию (trigger-захват, горизонт incremental) такой проход не завершает.
+func syncTableByKeys(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string, pkCols []string, numericPK bool) (complete bool, err error) {
     if len(pkCols) == 0 {
-        log.Printf("[WARN] Таблица %s не имеет PK (или не найдена). Используем полный дифф.", tableName)
-        return syncTableFullDiff(cfg, mainTx, tableName, nil)
+        logFrom(ctx).Warn(T("table has no PK (or not found), using full diff"))
+        return false, syncTableFullDiff(ctx, cfg, mainTx, tableName, nil)
     }
 
     // Если PK числовой и единственный, используем chunk-based
     if numericPK && len(pkCols) == 1 {
-        return syncTableByChunks(cfg, mainTx, tableName, pkCols[0])
+        return syncTableByChunks(ctx, cfg, mainTx, tableName, pkCols[0])
     }
 
     // Иначе (составной PK, строковый PK и т.д.) — полный дифф
-    log.Printf("[INFO] Таблица %s имеет составной/строковый PK или нет chunk-based, переходим на полный дифф.", tableName)
-    return syncTableFullDiff(cfg, mainTx, tableName, pkCols)
+    logFrom(ctx).Info(T("composite or non-numeric PK, using full diff"), "pk", pkCols)
+    if err := syncTableFullDiff(ctx, cfg, mainTx, tableName, pkCols); err != nil {
+        return false, err
+    }
+    return true, nil
 }
 
 // detectPK — пытается найти все столбцы, входящие в первичный ключ, 
 // а также проверяет, единственный ли он и является ли он "numeric" (int/bigint).
-func detectPK(tx *sql.Tx, schema, table string) ([]string, bool) {
+func detectPK(ctx context.Context, tx *sql.Tx, schema, table string) ([]string, bool) {
     query := `
 SELECT a.attname
 FROM pg_index i
@@ -58,9 +117,9 @@ WHERE i.indisprimary
   AND c.relname = $2
 ORDER BY a.attnum;
 `
-    rows, err := tx.QueryContext(context.Background(), query, schema, table)
+    rows, err := tx.QueryContext(snapshotContext(ctx), query, schema, table)
     if err != nil {
-        log.Printf("[detectPK] Ошибка при запросе PK для %s.%s: %v", schema, table, err)
+        logFrom(ctx).Error(T("PK lookup failed"), "error", err)
         return nil, false
     }
     defer rows.Close()
@@ -69,7 +128,7 @@ ORDER BY a.attnum;
     for rows.Next() {
         var col string
         if err := rows.Scan(&col); err != nil {
-            log.Printf("[detectPK] Ошибка Scan PK: %v", err)
+            logFrom(ctx).Error(T("PK scan failed"), "error", err)
             return nil, false
         }
         pkCols = append(pkCols, col)
@@ -81,7 +140,7 @@ ORDER BY a.attnum;
 
     // Если более одного столбца — считаем, что это составной ключ => numericPK = false
     if len(pkCols) > 1 {
-        log.Printf("[detectPK] Таблица %s: составной PK = %v, считаем numericPK=false", table, pkCols)
+        logFrom(ctx).Debug(T("composite PK, treating as non-numeric"), "pk", pkCols)
         return pkCols, false
     }
 
@@ -94,9 +153,9 @@ WHERE table_schema = $1
   AND column_name = $3
 `
     var dataType string
-    err = tx.QueryRowContext(context.Background(), colTypeQuery, schema, table, pkCols[0]).Scan(&dataType)
+    err = tx.QueryRowContext(snapshotContext(ctx), colTypeQuery, schema, table, pkCols[0]).Scan(&dataType)
     if err != nil {
-        log.Printf("[detectPK] Ошибка при чтении типа столбца %s: %v", pkCols[0], err)
+        logFrom(ctx).Error(T("PK column type lookup failed"), "column", pkCols[0], "error", err)
         return pkCols, false
     }
     dataType = strings.ToLower(dataType)
@@ -105,124 +164,261 @@ WHERE table_schema = $1
         numeric = true
     }
 
-    log.Printf("[detectPK] Таблица %s: PK=%v, numeric=%v", table, pkCols, numeric)
+    logFrom(ctx).Debug(T("PK detected"), "pk", pkCols, "numeric", numeric)
     return pkCols, numeric
 }
 
-// syncTableByChunks — разбивает PK-диапазон на чанки и синхронизирует каждый участок.
-func syncTableByChunks(cfg *Config, mainTx *sql.Tx, tableName, pkCol string) error {
-    schema := cfg.Schema
-    ctx := context.Background()
+// chunkPlan — всё, что нужно для прохода чанками по диапазону PK таблицы.
+// Общий для воркеров, которые параллельно обрабатывают части одной таблицы.
+type chunkPlan struct {
+    table        string
+    pkCol        string
+    columns      []string
+    casts        map[string]string
+    filter       string
+    tr           *tableTransform
+    minID, maxID int64 // весь диапазон PK таблицы
+}
 
-    // Получаем динамический список столбцов (из mainTx) для чтения строк
-    columns, err := getTableColumns(mainTx, schema, tableName)
+// prepareChunkPlan — столбцы, преобразования и MIN/MAX PK таблицы; nil, если синхронизировать нечего
+func prepareChunkPlan(ctx context.Context, cfg *Config, tx *sql.Tx, tableName, pkCol string) (*chunkPlan, error) {
+    // Столбцы для чтения строк: общие для main и standin (см. syncColumns)
+    columns, types, err := syncColumns(ctx, cfg, tx, tableName)
     if err != nil {
-        return fmt.Errorf("[syncTableByChunks] getTableColumns(%s): %v", tableName, err)
+        return nil, fmt.Errorf("[syncTableByChunks] syncColumns(%s): %v", tableName, err)
     }
     if len(columns) == 0 {
-        log.Printf("[Chunks] Таблица %s не имеет столбцов (?), пропускаем.", tableName)
-        return nil
+        logFrom(ctx).Warn(T("table has no columns, skipping"))
+        return nil, nil
     }
 
     // Считываем MIN и MAX значений PK
-    qMinMax := fmt.Sprintf(`SELECT COALESCE(MIN("%s"),0), COALESCE(MAX("%s"),0) FROM "%s"."%s"`,
-        pkCol, pkCol, schema, tableName)
+    filter := filterCond(cfg, tableName)
+    qMinMax := fmt.Sprintf(`SELECT COALESCE(MIN("%s"),0), COALESCE(MAX("%s"),0) FROM "%s"."%s" WHERE %s`,
+        pkCol, pkCol, cfg.Schema, tableName, filter)
     var minID, maxID int64
-    if err := mainTx.QueryRowContext(ctx, qMinMax).Scan(&minID, &maxID); err != nil {
-        return fmt.Errorf("[syncTableByChunks] MIN/MAX %s: %v", tableName, err)
+    if err := tx.QueryRowContext(snapshotContext(ctx), qMinMax).Scan(&minID, &maxID); err != nil {
+        return nil, fmt.Errorf("[syncTableByChunks] MIN/MAX %s: %v", tableName, err)
     }
     if maxID < minID {
-        log.Printf("[Chunks] Таблица %s пуста (min>max), пропускаем.", tableName)
-        return nil
+        logFrom(ctx).Info(T("table is empty, skipping"))
+        return nil, nil
+    }
+
+    tr, err := transformFor(cfg, tableName, columns, types, []string{pkCol})
+    if err != nil {
+        return nil, err
+    }
+    return &chunkPlan{
+        table:   tableName,
+        pkCol:   pkCol,
+        columns: columns,
+        casts:   castsFor(cfg, tableName),
+        filter:  filter,
+        tr:      tr,
+        minID:   minID,
+        maxID:   maxID,
+    }, nil
+}
+
+// syncTableByChunks — разбивает PK-диапазон на чанки и синхронизирует каждый участок.
+// complete — см. syncTableByKeys.
+func syncTableByChunks(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName, pkCol string) (complete bool, err error) {
+    sSchema, sTable := cfg.StandinSchema, standinTable(cfg, tableName)
+    plan, err := prepareChunkPlan(ctx, cfg, mainTx, tableName, pkCol)
+    if err != nil || plan == nil {
+        return err == nil, err
+    }
+
+    // --resume: продолжаем с места, где остановился прерванный запуск
+    startFrom := plan.minID
+    if cfg.Resume {
+        pos, ok, err := loadResumePoint(ctx, sSchema, sTable)
+        if err != nil {
+            return false, fmt.Errorf("[syncTableByChunks] loadResumePoint(%s): %v", tableName, err)
+        }
+        if ok && pos >= plan.minID {
+            startFrom = pos + 1
+            logFrom(ctx).Info(T("resuming interrupted run"), "chunk_start", startFrom)
+            progressFrom(ctx).setPosition(tableName, plan.minID, plan.maxID, pos)
+        }
+    }
+
+    skipped, err := syncChunkRange(ctx, cfg, mainTx, plan, startFrom, plan.maxID, false, func(lastDone int64) {
+        if recErr := recordInterruption(ctx, sSchema, sTable, lastDone); recErr != nil {
+            logFrom(ctx).Warn(T("failed to record resume point"), "error", recErr)
+        }
+    })
+    if err != nil {
+        return false, err
     }
 
-    log.Printf("[Chunks] Таблица %s, PK=[%d..%d], chunkSize=%d", tableName, minID, maxID, cfg.ChunkSize)
-    chunkSize := int64(cfg.ChunkSize)
+    if cfg.Resume {
+        if err := clearResumePoint(ctx, sSchema, sTable); err != nil {
+            logFrom(ctx).Warn(T("failed to clear resume point"), "error", err)
+        }
+    }
+    return skipped == 0 && startFrom == plan.minID, nil
+}
+
+// syncChunkRange — проходит чанками диапазон PK [from..to]. При остановке между чанками
+// вызывает onStop с последним полностью обработанным PK. shared — диапазон лишь часть
+// таблицы, которую параллельно проходят другие воркеры: прогресс копится долями, а не позицией.
+// skipped — сколько чанков пропущено из-за ошибок (они записаны в отчёт о проходе).
+func syncChunkRange(ctx context.Context, cfg *Config, tx *sql.Tx, plan *chunkPlan, from, to int64, shared bool, onStop func(lastDone int64)) (skipped int, err error) {
+    tableName, pkCol := plan.table, plan.pkCol
+    sSchema, sTable := cfg.StandinSchema, standinTable(cfg, tableName)
+    columns, casts, filter, tr := plan.columns, plan.casts, plan.filter, plan.tr
+
+    // Размер чанка подстраивается по замерам предыдущих чанков (см. chunkSizer)
+    sizer := newChunkSizer(cfg)
+    metricChunkSize.Set(tableName, float64(sizer.size))
+    logFrom(ctx).Info(T("chunked sync"), "pk_min", from, "pk_max", to, "chunk_size", sizer.size, "adaptive", sizer.adaptive())
 
     // Идём чанками
-    for start := minID; start <= maxID; start += chunkSize {
-        end := start + chunkSize - 1
-        if end > maxID {
-            end = maxID
+    var end int64
+    for start := from; start <= to; start = end + 1 {
+        // Остановка (сигнал, таймаут таблицы) — только между чанками; запоминаем, где встали.
+        // Пауза по нагрузке (--max-replica-lag, ...) тоже ждёт здесь и тоже прерывается остановкой.
+        release, err := throttleFrom(ctx).acquire(ctx)
+        if err == nil {
+            err = checkStop(ctx)
+        }
+        if err != nil {
+            release()
+            logFrom(ctx).Warn(T("stopping before chunk"), "chunk_start", start)
+            onStop(start - 1)
+            return skipped, err
+        }
+        end = start + sizer.size - 1
+        if end > to {
+            end = to
         }
+        chunkStart := time.Now()
+        cctx := withLogAttrs(ctx, "chunk_start", start, "chunk_end", end)
 
         // Читаем строки из mainDB
-        mainData, rowsMain, err := fetchRowsRange(mainTx, schema, tableName, pkCol, columns, start, end)
+        mainData, rowsMain, err := fetchRowsRange(snapshotContext(ctx), tx, cfg.Schema, tableName, pkCol, columns, casts, filter, tr, start, end)
+        release()
         if err != nil {
-            log.Printf("[syncTableByChunks] Ошибка fetchRowsRange main %s [%d..%d]: %v", tableName, start, end, err)
+            if stopErr := checkStop(ctx); stopErr != nil {
+                // Чанк не прочитан: точка --resume — перед ним, а не перед следующим
+                logFrom(ctx).Warn(T("stopping before chunk"), "chunk_start", start)
+                onStop(start - 1)
+                return skipped, stopErr
+            }
+            logFrom(cctx).Error(T("failed to read chunk from main"), "error", err)
+            metricErrors.Add("data", 1)
+            reportFrom(ctx).chunkFailed(tableName, "read_main", start, end, err)
+            skipped++
             continue
         }
-        // Читаем строки из standinDB
-        standinData, rowsStandin, err := fetchRowsRange(standinDB, schema, tableName, pkCol, columns, start, end)
+        // Читаем строки из standinDB (чтение вне транзакции — при обрыве соединения повторяем)
+        var standinData map[string]string
+        var rowsStandin map[string][]interface{}
+        err = retryTransient(ctx, "read "+sTable, func() error {
+            var err error
+            standinData, rowsStandin, err = fetchRowsRange(ctx, standinDB, sSchema, sTable, pkCol, columns, casts, filter, nil, start, end)
+            return err
+        })
         if err != nil {
-            log.Printf("[syncTableByChunks] Ошибка fetchRowsRange standin %s [%d..%d]: %v", tableName, start, end, err)
+            if stopErr := checkStop(ctx); stopErr != nil {
+                logFrom(ctx).Warn(T("stopping before chunk"), "chunk_start", start)
+                onStop(start - 1)
+                return skipped, stopErr
+            }
+            logFrom(cctx).Error(T("failed to read chunk from standin"), "error", err)
+            metricErrors.Add("data", 1)
+            reportFrom(ctx).chunkFailed(tableName, "read_standin", start, end, err)
+            skipped++
             continue
         }
 
+        var chunkBytes int64
+        for _, vals := range rowsMain {
+            chunkBytes += rowBytes(vals)
+        }
+        // Пауза по пределу скорости чтения не входит в длительность чанка, иначе адаптивный размер
+        // уменьшался бы из-за самого ограничения
+        throttleStart := time.Now()
+        if err := throttleFrom(ctx).consume(ctx, len(rowsMain), chunkBytes); err != nil {
+            logFrom(ctx).Warn(T("stopping before chunk"), "chunk_start", start)
+            onStop(start - 1)
+            return skipped, err
+        }
+        chunkStart = chunkStart.Add(time.Since(throttleStart))
+        adjust := func() {
+            d := time.Since(chunkStart)
+            if sizer.observe(d, chunkBytes) {
+                logChunkSize(cctx, tableName, sizer, d, chunkBytes)
+            }
+        }
+
         // Сравниваем
         toInsert, toUpdate, toDelete := compareData(mainData, standinData)
+        metricRowsCompared.Add(tableName, float64(len(mainData)))
+        progressFrom(ctx).addRows(tableName, len(mainData))
+        if shared {
+            progressFrom(ctx).addFraction(tableName, float64(end-start+1)/float64(plan.maxID-plan.minID+1))
+        } else {
+            progressFrom(ctx).setPosition(tableName, plan.minID, plan.maxID, end)
+        }
         if len(toInsert)+len(toUpdate)+len(toDelete) == 0 {
             // В этом чанке нет различий
+            metricChunks.Add(tableName, 1)
+            observeSince(metricChunkDuration, tableName, chunkStart)
+            adjust()
             continue
         }
 
         // Применяем
-        if err := applyChanges(tableName, schema, pkCol, columns, toInsert, toUpdate, toDelete, rowsMain, rowsStandin); err != nil {
-            log.Printf("[syncTableByChunks] Ошибка applyChanges chunk [%d..%d] (%s): %v", start, end, tableName, err)
+        if err := applyChanges(cctx, sTable, sSchema, pkCol, columns, toInsert, toUpdate, toDelete, rowsMain, rowsStandin); err != nil {
+            if stopErr := checkStop(ctx); stopErr != nil && !errors.Is(err, errSafety) {
+                // Остановка во время паузы между повторами: чанк не применён, продолжим с него
+                logFrom(ctx).Warn(T("stopping before chunk"), "chunk_start", start)
+                onStop(start - 1)
+                return skipped, stopErr
+            }
+            logFrom(cctx).Error(T("failed to apply chunk"), "error", err)
+            metricErrors.Add("data", 1)
+            if errors.Is(err, errSafety) {
+                return skipped, err
+            }
+            reportFrom(ctx).chunkFailed(tableName, "apply", start, end, err)
+            skipped++
         } else {
-            log.Printf("[Chunks] %s [%d..%d]: +%d / ~%d / -%d",
-                tableName, start, end, len(toInsert), len(toUpdate), len(toDelete))
-        }
-    }
-    return nil
-}
-
-// getTableColumns — получает список всех столбцов таблицы из information_schema.columns.
-func getTableColumns(db *sql.Tx, schema, table string) ([]string, error) {
-    query := `
-SELECT column_name
-FROM information_schema.columns
-WHERE table_schema = $1
-  AND table_name = $2
-ORDER BY ordinal_position;
-`
-    rows, err := db.QueryContext(context.Background(), query, schema, table)
-    if err != nil {
-        return nil, fmt.Errorf("getTableColumns: %v", err)
-    }
-    defer rows.Close()
-
-    var cols []string
-    for rows.Next() {
-        var colName string
-        if err := rows.Scan(&colName); err != nil {
-            return nil, err
+            logFrom(cctx).Info(T("chunk applied"), "inserted", len(toInsert), "updated", len(toUpdate), "deleted", len(toDelete))
+            metricRowsInserted.Add(tableName, float64(len(toInsert)))
+            metricRowsUpdated.Add(tableName, float64(len(toUpdate)))
+            metricRowsDeleted.Add(tableName, float64(len(toDelete)))
         }
-        cols = append(cols, colName)
+        metricChunks.Add(tableName, 1)
+        observeSince(metricChunkDuration, tableName, chunkStart)
+        adjust()
     }
-    if err := rows.Err(); err != nil {
-        return nil, err
-    }
-    return cols, nil
+    return skipped, nil
 }
 
-// fetchRowsRange — выбирает строки (все столбцы columns) из таблицы table
-func fetchRowsRange(db interface {
+// fetchRowsRange — выбирает строки (все столбцы columns, с приведениями casts) из таблицы table,
+// подходящие под filter. tr (для main) применяется до хеширования: сравниваются уже преобразованные значения.
+func fetchRowsRange(ctx context.Context, db interface {
     QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
 },
     schema, table, pkCol string,
     columns []string,
+    casts map[string]string,
+    filter string,
+    tr *tableTransform,
     start, end int64,
 ) (
     map[string]string,         // map[pk]->hash
     map[string][]interface{},  // map[pk]->rowValues
     error,
 ) {
-    ctx := context.Background()
-    colList := quoteColumns(columns) // "col1","col2",...
+    colList := selectColumns("", columns, casts) // "col1","col2"::type AS "col2",...
 
-    q := fmt.Sprintf(`SELECT %s FROM "%s"."%s" WHERE "%s" BETWEEN $1 AND $2`,
-        colList, schema, table, pkCol,
+    q := fmt.Sprintf(`SELECT %s FROM "%s"."%s" WHERE "%s" BETWEEN $1 AND $2 AND %s`,
+        colList, schema, table, pkCol, filter,
     )
     rows, err := db.QueryContext(ctx, q, start, end)
     if err != nil {
@@ -242,24 +438,31 @@ func fetchRowsRange(db interface {
         if err := rows.Scan(ptrs...); err != nil {
             return nil, nil, err
         }
+        tr.apply(vals)
 
         var pkVal string
-        var sb strings.Builder
         for i, c := range columns {
-            s := fmt.Sprintf("%v", vals[i])
             if c == pkCol {
-                pkVal = s
+                pkVal = fmt.Sprintf("%v", vals[i])
             }
-            sb.WriteString(s)
-            sb.WriteString("|")
         }
-        h := md5.Sum([]byte(sb.String()))
-        dataHash[pkVal] = hex.EncodeToString(h[:])
+        dataHash[pkVal] = rowHash(vals)
         dataRows[pkVal] = vals
     }
     return dataHash, dataRows, rows.Err()
 }
 
+// rowHash — md5 значений строки для сравнения main и standin
+func rowHash(vals []interface{}) string {
+    var sb strings.Builder
+    for _, v := range vals {
+        sb.WriteString(fmt.Sprintf("%v", v))
+        sb.WriteString("|")
+    }
+    h := md5.Sum([]byte(sb.String()))
+    return hex.EncodeToString(h[:])
+}
+
 // compareData — сравнивает mainData vs standinData по pk->hash и возвращает
 // списки pk для вставки, обновления, удаления.
 func compareData(mainData, standinData map[string]string) (insert, update, del []string) {
@@ -283,18 +486,38 @@ func compareData(mainData, standinData map[string]string) (insert, update, del [
 // для списка PK. При этом columns — динамический список столбцов, pkCol — имя PK-столбца,
 // rowsMain/rowsStandin содержат сырые данные ( []interface{} ), индексированные по pk.
 func applyChanges(
+    ctx context.Context,
     table, schema, pkCol string,
     columns []string,
     toInsert, toUpdate, toDelete []string,
     rowsMain, rowsStandin map[string][]interface{},
 ) error {
-    ctx := context.Background()
+    if err := checkDeleteAllowed(ctx, len(toDelete)); err != nil {
+        return err
+    }
+    // Транзакцию применения не обрывает ни Ctrl-C, ни таймаут таблицы;
+    // при временной ошибке она откатывается и выполняется заново
+    return retryTransient(ctx, "apply "+table, func() error {
+        return applyChangesTx(applyContext(ctx), table, schema, pkCol, columns, toInsert, toUpdate, toDelete, rowsMain)
+    })
+}
+
+// applyChangesTx — одна попытка применения чанка в транзакции standinDB
+func applyChangesTx(
+    ctx context.Context,
+    table, schema, pkCol string,
+    columns []string,
+    toInsert, toUpdate, toDelete []string,
+    rowsMain map[string][]interface{},
+) error {
     tx, err := standinDB.BeginTx(ctx, nil)
     if err != nil {
         return err
     }
     defer tx.Rollback()
 
+    deleted := len(toDelete)
+
     // 1) Подготовим batch upsert: INSERT ... ON CONFLICT DO UPDATE
     upsertRows := make([][]interface{}, 0, len(toInsert)+len(toUpdate))
     for _, pk := range toInsert {
@@ -303,26 +526,34 @@ func applyChanges(
     for _, pk := range toUpdate {
         upsertRows = append(upsertRows, rowsMain[pk])
     }
-    if len(upsertRows) > 0 {
-        if err := doBatchUpsertTx(ctx, tx, schema, table, columns, []string{pkCol}, upsertRows); err != nil {
+    // Адаптивный чанк может быть больше лимита параметров одного запроса — режем на пачки
+    batchSize := upsertBatchSize(len(columns), 0)
+    for len(upsertRows) > 0 {
+        n := min(batchSize, len(upsertRows))
+        if err := doBatchUpsertTx(ctx, tx, schema, table, columns, []string{pkCol}, upsertRows[:n]); err != nil {
             return err
         }
+        upsertRows = upsertRows[n:]
     }
 
     // 2) Удаление (batch delete pk IN (...))
-    if len(toDelete) > 0 {
-        placeholders := make([]string, len(toDelete))
-        args := make([]interface{}, len(toDelete))
-        for i, pk := range toDelete {
+    for len(toDelete) > 0 {
+        n := min(upsertBatchSize(1, 0), len(toDelete))
+        placeholders := make([]string, n)
+        args := make([]interface{}, n)
+        for i, pk := range toDelete[:n] {
             placeholders[i] = fmt.Sprintf("$%d", i+1)
             args[i] = pk
         }
+        toDelete = toDelete[n:]
         delSQL := fmt.Sprintf(
             `DELETE FROM "%s"."%s" WHERE "%s" IN (%s)`,
             schema, table, pkCol, strings.Join(placeholders, ","),
         )
         if _, err := tx.ExecContext(ctx, delSQL, args...); err != nil {
-            log.Printf("[DEL] Ошибка DELETE pk IN(...): %v", err)
+            // Транзакция после ошибки всё равно не зафиксируется — возвращаем причину, а не ошибку COMMIT
+            logFrom(ctx).Error(T("batch delete failed"), "error", err)
+            return err
         }
     }
 
@@ -330,13 +561,84 @@ func applyChanges(
         return err
     }
 
-    log.Printf("[applyChanges] %s: +%d / ~%d / -%d", table, len(toInsert), len(toUpdate), len(toDelete))
+    logFrom(ctx).Debug(T("changes committed"), "inserted", len(toInsert), "updated", len(toUpdate), "deleted", deleted)
 
     return nil
 }
 
+// applyKeyedBatch — применяет в одной транзакции standinDB upsert строк и удаление
+// по ключам. Ключи удаления — JSON-объекты {"pkCol": value, ...}, приводятся к типам
+// столбцов через jsonb_populate_record, поэтому подходят и для составных PK.
+func applyKeyedBatch(
+    ctx context.Context,
+    schema, table string,
+    columns, pkCols []string,
+    upsertRows [][]interface{},
+    deleteKeys []string,
+) error {
+    if err := checkDeleteAllowed(ctx, len(deleteKeys)); err != nil {
+        return err
+    }
+    return retryTransient(ctx, "apply "+table, func() error {
+        return applyKeyedBatchTx(applyContext(ctx), schema, table, columns, pkCols, upsertRows, deleteKeys)
+    })
+}
+
+// applyKeyedBatchTx — одна попытка applyKeyedBatch в транзакции standinDB
+func applyKeyedBatchTx(
+    ctx context.Context,
+    schema, table string,
+    columns, pkCols []string,
+    upsertRows [][]interface{},
+    deleteKeys []string,
+) error {
+    tx, err := standinDB.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if len(upsertRows) > 0 {
+        if err := doBatchUpsertTx(ctx, tx, schema, table, columns, pkCols, upsertRows); err != nil {
+            return err
+        }
+    }
+
+    if len(deleteKeys) > 0 {
+        conds := make([]string, len(pkCols))
+        for i, c := range pkCols {
+            conds[i] = fmt.Sprintf(`t."%[1]s" = d."%[1]s"`, c)
+        }
+        delSQL := fmt.Sprintf(`
+DELETE FROM "%[1]s"."%[2]s" t
+USING (SELECT (jsonb_populate_record(NULL::"%[1]s"."%[2]s", k)).* FROM jsonb_array_elements($1::jsonb) k) d
+WHERE %[3]s`,
+            schema, table, strings.Join(conds, " AND "))
+        keysJSON := "[" + strings.Join(deleteKeys, ",") + "]"
+        if _, err := tx.ExecContext(ctx, delSQL, keysJSON); err != nil {
+            return fmt.Errorf("DELETE по ключам: %w", err)
+        }
+    }
+
+    return tx.Commit()
+}
+
+// upsertBatchSize — сколько строк можно отправить одним INSERT, не превысив
+// лимит PostgreSQL в 65535 параметров, и не больше chunkSize.
+func upsertBatchSize(numCols, chunkSize int) int {
+    if numCols < 1 {
+        numCols = 1
+    }
+    n := 65535 / numCols
+    if chunkSize > 0 && chunkSize < n {
+        n = chunkSize
+    }
+    return n
+}
+
 // doBatchUpsertTx — формирует INSERT ... ON CONFLICT DO UPDATE, подставляя
-// все столбцы columns, кроме pkCols, в секцию DO UPDATE SET.
+// все столбцы columns, кроме pkCols, в секцию DO UPDATE SET. Столбцы GENERATED ALWAYS
+// AS IDENTITY вставляются с OVERRIDING SYSTEM VALUE и не обновляются (PostgreSQL это запрещает).
 func doBatchUpsertTx(
     ctx context.Context, tx *sql.Tx,
     schema, table string,
@@ -351,35 +653,124 @@ func doBatchUpsertTx(
     pkList := quoteColumns(pkCols)        // "pk"
     placeholders := makePlaceholderMatrix(len(rowValues), len(columns))
 
+    identity := alwaysIdentity(schema, table)
     var updateCols []string
     for _, c := range columns {
-        if !inSlice(pkCols, c) {
+        if !inSlice(pkCols, c) && !inSlice(identity, c) {
             updateCols = append(updateCols, fmt.Sprintf(`"%s"=EXCLUDED."%s"`, c, c))
         }
     }
+    conflict := "DO NOTHING"
+    if len(updateCols) > 0 {
+        conflict = "DO UPDATE SET " + strings.Join(updateCols, ", ")
+    }
     upsert := fmt.Sprintf(`
-INSERT INTO "%s"."%s" (%s)
+INSERT INTO "%s"."%s" (%s) %s
 VALUES %s
 ON CONFLICT (%s)
-DO UPDATE SET %s
+%s
 `,
-        schema, table, colList, placeholders, pkList, strings.Join(updateCols, ", "))
+        schema, table, colList, overridingClause(schema, table, columns), placeholders, pkList, conflict)
 
     args := flatten(rowValues)
     _, err := tx.ExecContext(ctx, upsert, args...)
     return err
 }
 
-// syncTableByUpdatedAt — заглушка для сценария, когда у каждой строки есть updated_at,
+// syncTableByUpdatedAt — сценарий, когда у каждой строки есть updated_at,
 // и мы синхронизируем только те, что обновились с момента cfg.LastSyncTime.
-func syncTableByUpdatedAt(cfg *Config, mainTx *sql.Tx, tableName string) error {
-    log.Printf("[UpdatedAt] Синхронизация %s c updated_at > %v (заглушка)", tableName, cfg.LastSyncTime)
+func syncTableByUpdatedAt(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string) error {
+    pkCols, _ := detectPK(ctx, mainTx, cfg.Schema, tableName)
+    if len(pkCols) == 0 {
+        logFrom(ctx).Warn(T("table has no PK, upsert impossible, using full diff"))
+        return syncTableFullDiff(ctx, cfg, mainTx, tableName, nil)
+    }
+
+    n, deleted, err := syncRowsWhere(ctx, cfg, mainTx, tableName, pkCols, `"updated_at" > $1`, cfg.LastSyncTime)
+    if err != nil {
+        return fmt.Errorf("[syncTableByUpdatedAt] %s: %v", tableName, err)
+    }
+    logFrom(ctx).Info(T("changed rows transferred"), "mode", "updated_at", "rows", n, "since", cfg.LastSyncTime)
+    if cfg.DeleteDetection == "soft" {
+        reportDeletes(ctx, "soft", deleted)
+    }
     return nil
 }
 
-// syncTableFullDiff — заглушка полного чтения (без чанков).
-func syncTableFullDiff(cfg *Config, mainTx *sql.Tx, tableName string, pkCols []string) error {
-    log.Printf("[FullDiff] Таблица %s: полный дифф. PKCols=%v", tableName, pkCols)
+// syncTableFullDiff — полное сравнение без чанков (составной или нечисловой PK):
+// обе стороны читаются целиком с учётом --filter и сопоставляются по ключу PK.
+func syncTableFullDiff(ctx context.Context, cfg *Config, mainTx *sql.Tx, tableName string, pkCols []string) error {
+    logFrom(ctx).Info(T("full diff"), "pk", pkCols)
+    if len(pkCols) == 0 {
+        logFrom(ctx).Warn(T("table has no PK, rows cannot be matched, skipping"))
+        return nil
+    }
+    schema := cfg.Schema
+
+    columns, types, err := syncColumns(ctx, cfg, mainTx, tableName)
+    if err != nil {
+        return fmt.Errorf("[syncTableFullDiff] syncColumns(%s): %v", tableName, err)
+    }
+    tr, err := transformFor(cfg, tableName, columns, types, pkCols)
+    if err != nil {
+        return err
+    }
+    filter := filterCond(cfg, tableName)
+    casts := castsFor(cfg, tableName)
+
+    release, err := throttleFrom(ctx).acquire(ctx)
+    if err != nil {
+        return err
+    }
+    mainData, rowsMain, err := fetchRowsByKey(snapshotContext(ctx), mainTx, schema, tableName, columns, casts, pkCols, filter, tr)
+    release()
+    if err != nil {
+        return fmt.Errorf("[syncTableFullDiff] чтение main %s: %v", tableName, err)
+    }
+    standinData, _, err := fetchRowsByKey(ctx, standinDB, cfg.StandinSchema, standinTable(cfg, tableName), columns, casts, pkCols, filter, nil)
+    if err != nil {
+        return fmt.Errorf("[syncTableFullDiff] чтение standin %s: %v", tableName, err)
+    }
+    metricRowsCompared.Add(tableName, float64(len(mainData)))
+    progressFrom(ctx).addRows(tableName, len(mainData))
+
+    toInsert, toUpdate, toDelete := compareData(mainData, standinData)
+    inserted, updated, deleted := len(toInsert), len(toUpdate), len(toDelete)
+    changed := make([]string, 0, inserted+updated)
+    changed = append(append(changed, toInsert...), toUpdate...)
+
+    // Применяем пачками: сначала upsert, затем удаления
+    batchSize := upsertBatchSize(len(columns), cfg.ChunkSize)
+    for len(changed) > 0 || len(toDelete) > 0 {
+        if err := checkStop(ctx); err != nil {
+            return err
+        }
+        var upserts [][]interface{}
+        var deleteKeys []string
+        n := batchSize
+        if n > len(changed) {
+            n = len(changed)
+        }
+        for _, k := range changed[:n] {
+            upserts = append(upserts, rowsMain[k])
+        }
+        changed = changed[n:]
+        if m := batchSize - n; m > 0 {
+            if m > len(toDelete) {
+                m = len(toDelete)
+            }
+            deleteKeys, toDelete = toDelete[:m], toDelete[m:]
+        }
+        if err := applyKeyedBatch(ctx, cfg.StandinSchema, standinTable(cfg, tableName), columns, pkCols, upserts, deleteKeys); err != nil {
+            return fmt.Errorf("[syncTableFullDiff] %s: %w", tableName, err)
+        }
+        metricChunks.Add(tableName, 1)
+    }
+
+    metricRowsInserted.Add(tableName, float64(inserted))
+    metricRowsUpdated.Add(tableName, float64(updated))
+    metricRowsDeleted.Add(tableName, float64(deleted))
+    logFrom(ctx).Info(T("changes committed"), "inserted", inserted, "updated", updated, "deleted", deleted)
     return nil
 }
 
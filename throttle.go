@@ -0,0 +1,216 @@
+package main
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// Ограничение нагрузки на main и реплики standin. Один throttle на процесс: его делят
+// все воркеры и все задания daemon-режима.
+//   - --max-rows-per-sec / --max-bytes-per-sec: после чтения пакета строк из main воркер
+//     ждёт, пока средняя скорость чтения не вернётся к пределу;
+//   - --max-main-queries: сколько запросов чтения main выполняется одновременно;
+//   - --max-replica-lag / --max-main-active-connections: пока отставание реплик standin
+//     (pg_stat_replication.replay_lag) или число активных соединений main выше порога,
+//     новые пакеты не начинаются. Проверка выполняется не чаще --throttle-check-interval.
+//     Команда export к standin не подключается, и отставание реплик для неё не проверяется.
+// Ожидание прерывается остановкой прохода и таймаутом таблицы, как и обработка чанков.
+
+// rateLimit — предел скорости в единицах в секунду: когда закончится уже прочитанное
+type rateLimit struct {
+    perSec int64
+    next   time.Time
+}
+
+// reserve — учитывает n единиц и возвращает, сколько ждать, чтобы уложиться в предел
+func (l *rateLimit) reserve(n int64) time.Duration {
+    if l.perSec <= 0 || n <= 0 {
+        return 0
+    }
+    now := time.Now()
+    if l.next.Before(now) {
+        l.next = now
+    }
+    l.next = l.next.Add(time.Duration(float64(n) / float64(l.perSec) * float64(time.Second)))
+    return l.next.Sub(now)
+}
+
+// throttle — общие для процесса ограничения нагрузки; методы nil-безопасны
+type throttle struct {
+    slots    chan struct{} // nil — число запросов к main не ограничено
+    maxLag   time.Duration
+    maxConns int
+    interval time.Duration
+
+    mu    sync.Mutex
+    rows  rateLimit
+    bytes rateLimit
+
+    checkMu   sync.Mutex
+    checkedAt time.Time
+    reason    string // почему обработка приостановлена; "" — нагрузка в норме
+    value     interface{}
+}
+
+type throttleKey struct{}
+
+// newThrottle — nil, если ни одно ограничение не задано
+func newThrottle(cfg *Config) *throttle {
+    if cfg.MaxRowsPerSec <= 0 && cfg.MaxBytesPerSec <= 0 && cfg.MaxMainQueries <= 0 &&
+        cfg.MaxReplicaLag <= 0 && cfg.MaxMainConnections <= 0 {
+        return nil
+    }
+    t := &throttle{
+        maxLag:   cfg.MaxReplicaLag,
+        maxConns: cfg.MaxMainConnections,
+        interval: cfg.ThrottleCheckInterval,
+        rows:     rateLimit{perSec: cfg.MaxRowsPerSec},
+        bytes:    rateLimit{perSec: cfg.MaxBytesPerSec},
+    }
+    if cfg.MaxMainQueries > 0 {
+        t.slots = make(chan struct{}, cfg.MaxMainQueries)
+    }
+    if t.interval <= 0 {
+        t.interval = time.Second
+    }
+    return t
+}
+
+// withThrottle — кладёт ограничитель в контекст
+func withThrottle(ctx context.Context, t *throttle) context.Context {
+    return context.WithValue(ctx, throttleKey{}, t)
+}
+
+// throttleFrom — ограничитель из контекста; nil, если ограничений нет
+func throttleFrom(ctx context.Context) *throttle {
+    t, _ := ctx.Value(throttleKey{}).(*throttle)
+    return t
+}
+
+// acquire — ждёт, пока нагрузка не придёт в норму, и занимает место для запроса к main.
+// release нужно вызвать по окончании чтения (и при ошибке тоже).
+func (t *throttle) acquire(ctx context.Context) (release func(), err error) {
+    release = func() {}
+    if t == nil {
+        return release, nil
+    }
+    if err := t.pause(ctx); err != nil {
+        return release, err
+    }
+    if t.slots == nil {
+        return release, nil
+    }
+    select {
+    case t.slots <- struct{}{}:
+        return func() { <-t.slots }, nil
+    default:
+    }
+    started := time.Now()
+    select {
+    case t.slots <- struct{}{}:
+        metricThrottleWait.Add("queries", time.Since(started).Seconds())
+        return func() { <-t.slots }, nil
+    case <-ctx.Done():
+        return release, checkStop(ctx)
+    }
+}
+
+// consume — учитывает прочитанные из main строки и байты, выдерживает паузу по пределу скорости
+// и ждёт, пока нагрузка не придёт в норму (для потокового чтения без acquire на каждый пакет)
+func (t *throttle) consume(ctx context.Context, rows int, bytes int64) error {
+    if t == nil {
+        return nil
+    }
+    t.mu.Lock()
+    wait, reason := t.rows.reserve(int64(rows)), "rows"
+    if w := t.bytes.reserve(bytes); w > wait {
+        wait, reason = w, "bytes"
+    }
+    t.mu.Unlock()
+    if wait > 0 {
+        metricThrottleWait.Add(reason, wait.Seconds())
+        if err := sleepCtx(ctx, wait); err != nil {
+            return err
+        }
+    }
+    return t.pause(ctx)
+}
+
+// pause — ждёт, пока отставание реплик standin и число активных соединений main не опустятся ниже порогов
+func (t *throttle) pause(ctx context.Context) error {
+    if t.maxLag <= 0 && t.maxConns <= 0 {
+        return nil
+    }
+    for {
+        reason := t.check(ctx)
+        if reason == "" {
+            return nil
+        }
+        metricThrottleWait.Add(reason, t.interval.Seconds())
+        if err := sleepCtx(ctx, t.interval); err != nil {
+            return err
+        }
+    }
+}
+
+// check — причина паузы по последней проверке; проверка повторяется раз в interval
+func (t *throttle) check(ctx context.Context) string {
+    t.checkMu.Lock()
+    defer t.checkMu.Unlock()
+    if !t.checkedAt.IsZero() && time.Since(t.checkedAt) < t.interval {
+        return t.reason
+    }
+    t.checkedAt = time.Now()
+
+    reason, value := "", interface{}(nil)
+    if t.maxLag > 0 && standinDB != nil {
+        // NULL — реплик нет или они догнали: replay_lag обнуляется при простое
+        var lagSec float64
+        err := standinDB.QueryRowContext(ctx, `
+SELECT COALESCE(EXTRACT(EPOCH FROM MAX(replay_lag)), 0)::float8
+FROM pg_stat_replication`).Scan(&lagSec)
+        if err != nil {
+            // Без прав pg_monitor и при сбое проверки нагрузку не ограничиваем
+            logFrom(ctx).Warn(T("throttle check failed"), "check", "replica_lag", "error", err)
+        } else if lag := time.Duration(lagSec * float64(time.Second)); lag > t.maxLag {
+            reason, value = "replica_lag", lag.Round(time.Millisecond)
+        }
+    }
+    if reason == "" && t.maxConns > 0 {
+        var active int
+        err := mainDB.QueryRowContext(ctx, `
+SELECT count(*)
+FROM pg_stat_activity
+WHERE state = 'active'
+  AND backend_type = 'client backend'
+  AND pid <> pg_backend_pid()`).Scan(&active)
+        if err != nil {
+            logFrom(ctx).Warn(T("throttle check failed"), "check", "main_connections", "error", err)
+        } else if active > t.maxConns {
+            reason, value = "main_connections", active
+        }
+    }
+
+    switch {
+    case reason != "" && t.reason == "":
+        logFrom(ctx).Warn(T("load too high, pausing chunk processing"), "reason", reason, "value", value,
+            "max_replica_lag", t.maxLag, "max_main_active_connections", t.maxConns)
+    case reason == "" && t.reason != "":
+        logFrom(ctx).Info(T("load back to normal, resuming chunk processing"), "paused_by", t.reason, "last_value", t.value)
+    }
+    t.reason, t.value = reason, value
+    return reason
+}
+
+// sleepCtx — пауза d, прерываемая остановкой прохода или таймаутом таблицы
+func sleepCtx(ctx context.Context, d time.Duration) error {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-ctx.Done():
+        return checkStop(ctx)
+    }
+}
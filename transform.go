@@ -0,0 +1,306 @@
+package main
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Преобразования столбцов для непродовых standin (маскирование PII).
+// Правило задаётся флагом --transform "таблица.столбец=правило", таблица "*" — любая:
+//   null                — NULL;
+//   fixed:<значение>    — константа (приводится к типу столбца);
+//   hash                — HMAC-SHA256 значения с ключом --transform-key в виде значения типа столбца
+//                         (целое, numeric, uuid или текст не короче 32 символов);
+//   pseudonym[:префикс] — читаемый псевдоним <префикс>_<hmac>;
+//   email / phone       — детерминированные поддельные адрес и телефон;
+//   month               — дата/время, усечённые до первого числа месяца.
+// Все ключевые правила зависят только от значения и ключа, поэтому одинаковые значения
+// в разных таблицах превращаются в одинаковые, и join'ы по ним сохраняются.
+// Правила применяются к строкам main сразу после чтения — до хеширования для сравнения
+// и до upsert, так что повторный запуск не видит различий. Столбцы PK преобразовывать нельзя:
+// по ним ищутся строки standin.
+
+// columnTransform — правило для одного столбца
+type columnTransform struct {
+    kind  string // null, fixed, hash, pseudonym, email, phone, month
+    arg   string
+    typ   string      // тип столбца в целевой таблице (format_type): по нему строятся значения hash и fixed
+    fixed interface{} // константа fixed, уже приведённая к типу typ
+}
+
+// transformRules — правила --transform: таблица ("*" — любая) -> столбец -> правило
+type transformRules map[string]map[string]columnTransform
+
+// tableTransform — правила таблицы, привязанные к позициям столбцов в columns
+type tableTransform struct {
+    key   []byte
+    rules map[int]columnTransform
+}
+
+// parseTransforms — разбирает значения --transform в map[таблица]map[столбец]правило
+func parseTransforms(specs []string) (transformRules, error) {
+    out := make(transformRules)
+    for _, spec := range specs {
+        eq := strings.Index(spec, "=")
+        dot := strings.Index(spec, ".")
+        if eq < 0 || dot <= 0 || dot > eq {
+            return nil, fmt.Errorf("неверное правило transform %q (ожидается таблица.столбец=правило)", spec)
+        }
+        table, column := strings.TrimSpace(spec[:dot]), strings.TrimSpace(spec[dot+1:eq])
+        rule := strings.TrimSpace(spec[eq+1:])
+
+        t := columnTransform{kind: rule}
+        if i := strings.Index(rule, ":"); i >= 0 {
+            t.kind, t.arg = rule[:i], rule[i+1:]
+        }
+        switch t.kind {
+        case "null", "hash", "email", "phone", "month":
+            if t.arg != "" {
+                return nil, fmt.Errorf("правило %s в %q не принимает аргумент", t.kind, spec)
+            }
+        case "fixed", "pseudonym":
+        default:
+            return nil, fmt.Errorf("неизвестное правило %q в %q (допустимо: null, fixed:<v>, hash, pseudonym[:prefix], email, phone, month)", t.kind, spec)
+        }
+
+        if out[table] == nil {
+            out[table] = make(map[string]columnTransform)
+        }
+        if _, dup := out[table][column]; dup {
+            return nil, fmt.Errorf("для %s.%s правило transform задано дважды", table, column)
+        }
+        out[table][column] = t
+    }
+    return out, nil
+}
+
+// needsTransformKey — есть ли правила, которым нужен --transform-key
+func needsTransformKey(rules transformRules) bool {
+    for _, cols := range rules {
+        for _, t := range cols {
+            switch t.kind {
+            case "hash", "pseudonym", "email", "phone":
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// transformFor — правила для таблицы с конкретным порядком столбцов; nil, если правил нет.
+// types — типы столбцов columns в целевой таблице. Правило таблицы важнее правила "*" для того же столбца.
+func transformFor(cfg *Config, tableName string, columns, types, pkCols []string) (*tableTransform, error) {
+    if len(cfg.Transforms) == 0 {
+        return nil, nil
+    }
+    rules := make(map[int]columnTransform)
+    for i, c := range columns {
+        t, ok := cfg.Transforms[tableName][c]
+        if !ok {
+            t, ok = cfg.Transforms["*"][c]
+        }
+        if !ok {
+            continue
+        }
+        if inSlice(pkCols, c) {
+            return nil, fmt.Errorf("столбец %s.%s входит в PK — его нельзя преобразовывать (transform)", tableName, c)
+        }
+        t.typ = types[i]
+        switch t.kind {
+        case "hash":
+            if !hashSupported(t.typ) {
+                return nil, fmt.Errorf("правило hash для %s.%s: тип %s не поддерживается "+
+                    "(допустимо: smallint, integer, bigint, numeric с целой частью, uuid, text, varchar не короче 32)", tableName, c, t.typ)
+            }
+        case "fixed":
+            v, ok := fixedValue(t.arg, t.typ)
+            if !ok {
+                return nil, fmt.Errorf("правило fixed для %s.%s: значение %q не приводится к типу %s", tableName, c, t.arg, t.typ)
+            }
+            t.fixed = v
+        }
+        rules[i] = t
+    }
+    if len(rules) == 0 {
+        return nil, nil
+    }
+    return &tableTransform{key: []byte(cfg.TransformKey), rules: rules}, nil
+}
+
+// apply — преобразует значения строки на месте (nil-безопасно)
+func (t *tableTransform) apply(vals []interface{}) {
+    if t == nil {
+        return
+    }
+    for i, r := range t.rules {
+        vals[i] = t.value(r, vals[i])
+    }
+}
+
+func (t *tableTransform) value(r columnTransform, v interface{}) interface{} {
+    if r.kind == "null" {
+        return nil
+    }
+    if r.kind == "fixed" {
+        return r.fixed
+    }
+    // Остальные правила сохраняют NULL
+    if v == nil {
+        return nil
+    }
+
+    switch r.kind {
+    case "month":
+        if tm, ok := v.(time.Time); ok {
+            return time.Date(tm.Year(), tm.Month(), 1, 0, 0, 0, 0, tm.Location())
+        }
+        return v
+    case "hash":
+        return hashValue(r.typ, t.mac("hash", v))
+    case "pseudonym":
+        prefix := r.arg
+        if prefix == "" {
+            prefix = "anon"
+        }
+        return prefix + "_" + hex.EncodeToString(t.mac("pseudonym", v)[:5])
+    case "email":
+        return "user_" + hex.EncodeToString(t.mac("email", v)[:6]) + "@example.invalid"
+    case "phone":
+        n := binary.BigEndian.Uint64(t.mac("phone", v)) % 10000000
+        return fmt.Sprintf("+1555%07d", n)
+    }
+    return v
+}
+
+// mac — HMAC-SHA256(key, правило|значение): у разных правил разные выходы для одного значения
+func (t *tableTransform) mac(kind string, v interface{}) []byte {
+    h := hmac.New(sha256.New, t.key)
+    h.Write([]byte(kind))
+    h.Write([]byte{0})
+    h.Write([]byte(transformText(v)))
+    return h.Sum(nil)
+}
+
+// transformText — текстовое представление значения для HMAC
+func transformText(v interface{}) string {
+    switch x := v.(type) {
+    case []byte:
+        return string(x)
+    case string:
+        return x
+    case time.Time:
+        return x.UTC().Format(time.RFC3339Nano)
+    }
+    return fmt.Sprintf("%v", v)
+}
+
+// hashSupported — можно ли записать результат hash в столбец типа typ так, чтобы
+// прочитанное из standin значение совпадало с вычисленным (иначе строка переписывалась бы
+// на каждом запуске) и вставка не падала: даты, числа с плавающей точкой, короткие строки — нельзя.
+func hashSupported(typ string) bool {
+    switch typ {
+    case "smallint", "integer", "bigint", "uuid", "text", "character varying", "citext":
+        return true
+    }
+    if strings.HasPrefix(typ, "numeric") {
+        digits, _, ok := numericDigits(typ)
+        return ok && digits > 0
+    }
+    var n int
+    if _, err := fmt.Sscanf(typ, "character varying(%d)", &n); err == nil {
+        return n >= 32
+    }
+    return false
+}
+
+// hashValue — значение hash для столбца типа typ (см. hashSupported).
+// Целые остаются целыми (например, внешние идентификаторы) в пределах своего типа:
+// pgx отдаёт smallint и integer тоже как int64.
+func hashValue(typ string, mac []byte) interface{} {
+    switch typ {
+    case "smallint":
+        return int64(binary.BigEndian.Uint16(mac) >> 1)
+    case "integer":
+        return int64(binary.BigEndian.Uint32(mac) >> 1)
+    case "bigint":
+        return int64(binary.BigEndian.Uint64(mac) >> 1)
+    case "uuid":
+        // Версия 4 и вариант RFC 4122; текст — в том виде, в каком его читает pgx
+        u := make([]byte, 16)
+        copy(u, mac)
+        u[6] = u[6]&0x0f | 0x40
+        u[8] = u[8]&0x3f | 0x80
+        return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+    }
+    if strings.HasPrefix(typ, "numeric") {
+        // Целое в пределах точности столбца; дробная часть — нули по масштабу, как numeric выводит её сам
+        digits, scale, _ := numericDigits(typ)
+        n := (binary.BigEndian.Uint64(mac) >> 1) % pow10(digits)
+        if scale == 0 {
+            return strconv.FormatUint(n, 10)
+        }
+        return strconv.FormatUint(n, 10) + "." + strings.Repeat("0", scale)
+    }
+    return hex.EncodeToString(mac[:16])
+}
+
+// numericDigits — число цифр целой части (не больше 18 — чтобы уместиться в uint64)
+// и масштаб типа numeric[(p[,s])]; numeric без точности — 18 цифр без дробной части
+func numericDigits(typ string) (digits, scale int, ok bool) {
+    if typ == "numeric" {
+        return 18, 0, true
+    }
+    var p int
+    if _, err := fmt.Sscanf(typ, "numeric(%d,%d)", &p, &scale); err != nil {
+        return 0, 0, false
+    }
+    if scale < 0 {
+        return 0, 0, false
+    }
+    return min(p-scale, 18), scale, true
+}
+
+// pow10 — 10^n для n <= 19
+func pow10(n int) uint64 {
+    r := uint64(1)
+    for i := 0; i < n; i++ {
+        r *= 10
+    }
+    return r
+}
+
+// fixedValue — константа правила fixed, приведённая к Go-типу, в котором pgx читает
+// столбец типа typ. Иначе, например, строка "2020-01-01" и прочитанный из standin time.Time
+// хешировались бы по-разному только из-за представления, и сравнение находило бы различия
+// на каждом запуске. ok=false — значение к типу не приводится.
+func fixedValue(s, typ string) (v interface{}, ok bool) {
+    switch {
+    case typ == "smallint" || typ == "integer" || typ == "bigint":
+        bits := map[string]int{"smallint": 16, "integer": 32, "bigint": 64}[typ]
+        n, err := strconv.ParseInt(s, 10, bits)
+        return n, err == nil
+    case typ == "real" || typ == "double precision":
+        f, err := strconv.ParseFloat(s, 64)
+        return f, err == nil
+    case typ == "boolean":
+        b, err := strconv.ParseBool(s)
+        return b, err == nil
+    case typ == "date" || strings.HasPrefix(typ, "timestamp"):
+        // Сравнение идёт по UTC (см. transformText), поэтому зона разбора не важна
+        for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+            if tm, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+                return tm, true
+            }
+        }
+        return nil, false
+    case typ == "bytea" || typ == "json" || typ == "jsonb":
+        return []byte(s), true
+    }
+    return s, true
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "time"
+)
+
+// Карантин лишних таблиц standin (--clean-mode=quarantine): вместо DROP ... CASCADE
+// таблица переносится в схему pgsyncer_trash под именем <таблица>__<время>.
+// Представления и внешние ключи, созданные на standin, продолжают ссылаться на неё
+// (PostgreSQL хранит ссылки по OID), поэтому ничего не теряется.
+// Команда purge удаляет из карантина таблицы старше --trash-retention.
+
+const (
+    trashSchema     = "pgsyncer_trash"
+    trashTimeLayout = "20060102T150405"
+)
+
+// trashNameRe — <таблица>__<время> в имени таблицы карантина
+var trashNameRe = regexp.MustCompile(`__(\d{8}T\d{6})$`)
+
+// trashName — имя таблицы в карантине; исходное имя укорачивается под лимит 63 байта
+func trashName(table string, at time.Time) string {
+    suffix := "__" + at.UTC().Format(trashTimeLayout)
+    if max := 63 - len(suffix); len(table) > max {
+        table = table[:max]
+    }
+    return table + suffix
+}
+
+// quarantineTable — переносит таблицу standin в pgsyncer_trash (переименование и смена схемы в одной транзакции)
+func quarantineTable(ctx context.Context, schema, table string) (string, error) {
+    name := trashName(table, time.Now())
+
+    tx, err := standinDB.BeginTx(ctx, nil)
+    if err != nil {
+        return "", err
+    }
+    defer tx.Rollback()
+
+    stmts := []string{
+        fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, trashSchema),
+        // Сначала переименовываем на месте: в pgsyncer_trash уже может лежать одноимённая таблица
+        fmt.Sprintf(`ALTER TABLE "%s"."%s" RENAME TO "%s"`, schema, table, name),
+        fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA %s`, schema, name, trashSchema),
+        fmt.Sprintf(`COMMENT ON TABLE %s."%s" IS 'pgsyncer: %s.%s'`, trashSchema, name, schema, table),
+    }
+    for _, s := range stmts {
+        if _, err := tx.ExecContext(ctx, s); err != nil {
+            return "", fmt.Errorf("%s: %v", s, err)
+        }
+    }
+    return name, tx.Commit()
+}
+
+// cascadeDependents — объекты standin, которые DROP ... CASCADE удалил бы вместе с таблицей:
+// представления (в том числе построенные на других представлениях) и внешние ключи.
+func cascadeDependents(ctx context.Context, schema, table string) ([]string, error) {
+    // Представление зависит от таблицы через правило _RETURN (pg_rewrite): для рекурсии
+    // такие зависимости сводим к самому представлению (pg_class, ev_class).
+    q := `
+WITH RECURSIVE deps(classid, objid, objsubid, refcl, refob) AS (
+    SELECT d.classid, d.objid, d.objsubid,
+           CASE WHEN d.classid = 'pg_rewrite'::regclass THEN 'pg_class'::regclass::oid ELSE d.classid END,
+           CASE WHEN d.classid = 'pg_rewrite'::regclass
+                THEN (SELECT r.ev_class FROM pg_rewrite r WHERE r.oid = d.objid) ELSE d.objid END
+    FROM pg_depend d
+    WHERE d.refclassid = 'pg_class'::regclass
+      AND d.refobjid = to_regclass($1::text)
+      AND d.deptype = 'n'
+  UNION
+    SELECT d.classid, d.objid, d.objsubid,
+           CASE WHEN d.classid = 'pg_rewrite'::regclass THEN 'pg_class'::regclass::oid ELSE d.classid END,
+           CASE WHEN d.classid = 'pg_rewrite'::regclass
+                THEN (SELECT r.ev_class FROM pg_rewrite r WHERE r.oid = d.objid) ELSE d.objid END
+    FROM pg_depend d
+    JOIN deps p ON d.refclassid = p.refcl AND d.refobjid = p.refob
+    WHERE d.deptype = 'n'
+)
+SELECT DISTINCT CASE WHEN classid = 'pg_rewrite'::regclass
+                     THEN 'view ' || refob::regclass::text
+                     ELSE pg_describe_object(classid, objid, objsubid) END
+FROM deps
+WHERE refob <> to_regclass($1::text)
+ORDER BY 1
+`
+    rows, err := standinDB.QueryContext(ctx, q, fmt.Sprintf(`"%s"."%s"`, schema, table))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var deps []string
+    for rows.Next() {
+        var d string
+        if err := rows.Scan(&d); err != nil {
+            return nil, err
+        }
+        deps = append(deps, d)
+    }
+    return deps, rows.Err()
+}
+
+// reportDependents — пишет в лог, что CASCADE удалил бы (или удалит) вместе с таблицей
+func reportDependents(ctx context.Context, schema, table string) {
+    deps, err := cascadeDependents(ctx, schema, table)
+    if err != nil {
+        logFrom(ctx).Warn(T("failed to list dependent objects"), "table", table, "error", err)
+        return
+    }
+    if len(deps) > 0 {
+        logFrom(ctx).Warn(T("dependent objects CASCADE would remove"), "table", table, "objects", deps)
+    }
+}
+
+// purgeTrash — команда purge: удаляет из pgsyncer_trash таблицы старше --trash-retention
+func purgeTrash(ctx context.Context, cfg *Config) error {
+    ctx = withLogAttrs(ctx, "phase", "purge")
+
+    tables, err := listTables(ctx, standinDB, trashSchema)
+    if err != nil {
+        return fmt.Errorf("listTables(%s): %v", trashSchema, err)
+    }
+
+    cutoff := time.Now().Add(-cfg.TrashRetention)
+    purged := 0
+    for _, t := range tables {
+        m := trashNameRe.FindStringSubmatch(t)
+        if m == nil {
+            logFrom(ctx).Warn(T("not a quarantined table, skipping"), "table", t)
+            continue
+        }
+        at, err := time.Parse(trashTimeLayout, m[1])
+        if err != nil || at.After(cutoff) {
+            continue
+        }
+        if err := checkStop(ctx); err != nil {
+            return err
+        }
+
+        reportDependents(ctx, trashSchema, t)
+        if _, err := standinDB.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %s."%s" CASCADE`, trashSchema, t)); err != nil {
+            return fmt.Errorf("DROP %s.%s: %v", trashSchema, t, err)
+        }
+        logFrom(ctx).Info(T("quarantined table purged"), "table", t, "quarantined_at", at)
+        purged++
+    }
+    logFrom(ctx).Info(T("trash purge finished"), "purged", purged, "kept", len(tables)-purged, "retention", cfg.TrashRetention)
+    return nil
+}